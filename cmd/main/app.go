@@ -2,58 +2,185 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/julienschmidt/httprouter"
-	"net"
-	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 	"tz1/internal/subscription"
 	sdb "tz1/internal/subscription/db"
+	"tz1/pkg/apperror"
 	"tz1/pkg/client/postgresql"
 	"tz1/pkg/config"
 	"tz1/pkg/logging"
 )
 
-func main() {
-	logger := logging.GetLogger()
-	logger.Info("create router")
-	router := httprouter.New()
+// Exit codes of the commands.
+const (
+	exitOK = iota
+	// exitError reports a failure such as an unreachable database.
+	exitError
+	// exitUsage reports invalid arguments or input data.
+	exitUsage
+	// exitPartial reports an import where some records were rejected.
+	exitPartial
+)
 
-	cfg := config.GetConfig()
+type command struct {
+	usage string
+	run   func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"serve":   {usage: serveUsage, run: runServe},
+	"migrate": {usage: migrateUsage, run: runMigrate},
+	"import":  {usage: importUsage, run: runImport},
+	"export":  {usage: exportUsage, run: runExport},
+	"sum":     {usage: sumUsage, run: runSum},
+}
+
+// usageError reports invalid command line arguments.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
 
-	postgreSQLClient, err := postgresql.NewClient(context.TODO(), 6, cfg.Storage)
+func usageErrorf(format string, a ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+var (
+	// errPartial is returned by commands that finished but rejected some input.
+	errPartial = errors.New("some records were rejected")
+	// errFlags is returned when the flag package has already printed the
+	// parse error together with the usage.
+	errFlags = errors.New("invalid flags")
+)
+
+// newFlagSet returns a flag set for a command that prints the command usage
+// followed by its flags.
+func newFlagSet(name, cmdUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s\n\nflags:\n", cmdUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errFlags
+	}
+	return err
+}
+
+// openRepository connects to the database for the commands that work with
+// subscriptions directly. The returned function closes the connection pool.
+func openRepository(ctx context.Context, cfg *config.Config) (subscription.Repository, func(), error) {
+	pool, err := postgresql.NewClient(ctx, 6, cfg.Storage)
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return nil, nil, err
 	}
+	return sdb.NewRepository(pool, logging.GetLogger()), pool.Close, nil
+}
 
-	logger.Info("register subscription handler")
-	sRep := sdb.NewRepository(postgreSQLClient, logger)
-	sHandler := subscription.NewHandler(sRep, logger)
-	sHandler.Register(router)
+// writeJSON writes v to stdout as a single JSON document.
+func writeJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
 
-	start(router, cfg)
+func main() {
+	os.Exit(run())
 }
 
-func start(router *httprouter.Router, cfg *config.Config) {
+func run() int {
+	configPath := flag.String("config", "", "path to the configuration file (default config.yml, optional)")
+	flag.Usage = usage
+	flag.Parse()
+	if *configPath != "" {
+		config.SetPath(*configPath)
+	}
+
+	name, args := "serve", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", name)
+		flag.Usage()
+		return exitUsage
+	}
+	if name != "serve" {
+		// keep stdout for the command output
+		logging.SetConsole(os.Stderr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	logger := logging.GetLogger()
-	logger.Info("start application")
+	cfg := config.GetConfig()
 
-	var listener net.Listener
-	var listenErr error
+	if err := logging.Init(cfg.Logging); err != nil {
+		logger.Error(err)
+		return exitError
+	}
 
-	logger.Info("listen tcp")
-	listener, listenErr = net.Listen("tcp", fmt.Sprintf("%s:%s", cfg.Listen.BindIp, cfg.Listen.Port))
-	logger.Infof("server is listening port %s:%s", cfg.Listen.BindIp, cfg.Listen.Port)
+	return exitCode(cmd.run(ctx, cfg, args), cmd.usage)
+}
+
+// exitCode reports err on stderr, as JSON unless it is a usage error, and
+// maps it to the exit code.
+func exitCode(err error, cmdUsage string) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "%s\nusage: %s\n", usageErr.msg, cmdUsage)
+		return exitUsage
+	}
+	if errors.Is(err, errFlags) {
+		return exitUsage
+	}
+	if errors.Is(err, errPartial) {
+		return exitPartial
+	}
 
-	if listenErr != nil {
-		logger.Fatal(listenErr)
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) {
+		appErr = apperror.NewAppError(err, err.Error(), "", "")
 	}
+	out, _ := json.Marshal(appErr)
+	fmt.Fprintln(os.Stderr, string(out))
 
-	server := &http.Server{
-		Handler:      router,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+	if appErr.Code != "" && appErr.Code != "US-000000" {
+		return exitUsage
 	}
+	return exitError
+}
 
-	logger.Fatal(server.Serve(listener))
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: %s [--config file] <command> [arguments]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(out, "\nserve is the default command.\n\nflags:\n")
+	flag.PrintDefaults()
 }
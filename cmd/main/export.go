@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"tz1/internal/subscription"
+	"tz1/pkg/config"
+)
+
+const exportUsage = "export [--format csv|json] [--output file] [--from MM-YYYY] [--to MM-YYYY] [--date-mode starts_in|active_in|ends_in] [--user id] [--service name] [--sort fields]"
+
+// runExport writes the subscriptions matching the filters of GET
+// /subscriptions as CSV or as a JSON array.
+func runExport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("export", exportUsage)
+	format := fs.String("format", "csv", "csv or json")
+	output := fs.String("output", "", "file to write, stdout when empty")
+	from := fs.String("from", "", "first month of the window, MM-YYYY")
+	to := fs.String("to", "", "last month of the window, MM-YYYY")
+	dateMode := fs.String("date-mode", "", "starts_in, active_in or ends_in (default starts_in)")
+	user := fs.String("user", "", "only subscriptions of this user id")
+	service := fs.String("service", "", "only subscriptions of this service")
+	var sort []string
+	fs.Func("sort", "comma separated fields to order by, prefixed with - for descending", func(v string) error {
+		sort = strings.Split(v, ",")
+		return nil
+	})
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments: %v", fs.Args())
+	}
+	if *format != "csv" && *format != "json" {
+		return usageErrorf("unknown format: %s", *format)
+	}
+
+	repository, closeRepository, err := openRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	filter := subscription.ListFilter{From: *from, To: *to, DateMode: *dateMode, Sort: sort}
+	if *user != "" {
+		filter.Users = []string{*user}
+	}
+	if *service != "" {
+		filter.Services = []string{*service}
+	}
+	if *format == "csv" {
+		err = exportCSV(ctx, repository, bw, filter)
+	} else {
+		err = exportJSON(ctx, repository, bw, filter)
+	}
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func exportCSV(ctx context.Context, repository subscription.Repository, w io.Writer, filter subscription.ListFilter) error {
+	cw, err := subscription.NewCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	if err = repository.Export(ctx, filter, cw.Write); err != nil {
+		return err
+	}
+	return cw.Flush()
+}
+
+// exportJSON writes a JSON array with one subscription per line.
+func exportJSON(ctx context.Context, repository subscription.Repository, w io.Writer, filter subscription.ListFilter) error {
+	sep := "["
+	err := repository.Export(ctx, filter, func(s subscription.Subscription) error {
+		sBytes, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, sep+"\n"); err != nil {
+			return err
+		}
+		sep = ","
+		_, err = w.Write(sBytes)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if sep == "[" {
+		_, err = io.WriteString(w, "[]\n")
+		return err
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
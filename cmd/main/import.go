@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
+	"tz1/pkg/config"
+)
+
+const importUsage = "import [--format csv|json] [--dry-run] [--on-conflict skip|update] <file|->"
+
+// runImport imports the subscriptions read from a CSV file or a JSON array the
+// same way as POST /subscriptions/import and prints the import result.
+// Subscriptions that already exist are skipped unless --on-conflict=update, so
+// a file can be imported again after fixing the rejected records.
+func runImport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("import", importUsage)
+	format := fs.String("format", "", "csv or json (default by file extension)")
+	var options subscription.ImportOptions
+	fs.BoolVar(&options.DryRun, "dry-run", false, "report what would be imported without writing anything")
+	onConflict := fs.String("on-conflict", "skip", "skip or update existing subscriptions")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf("expected exactly one file")
+	}
+	switch *onConflict {
+	case "skip":
+	case "update":
+		options.Update = true
+	default:
+		return usageErrorf("unknown --on-conflict value %q", *onConflict)
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if *format != "csv" && *format != "json" {
+		return usageErrorf("unknown format %q, set --format", *format)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var rows subscription.ImportReader
+	var err error
+	if *format == "csv" {
+		rows, err = subscription.NewCSVReader(in)
+	} else {
+		rows, err = newJSONReader(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	repository, closeRepository, err := openRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+
+	result, err := repository.Import(ctx, rows, options)
+	if err != nil {
+		return err
+	}
+
+	if err = writeJSON(result); err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return errPartial
+	}
+
+	return nil
+}
+
+// jsonReader reads the subscriptions of a JSON array one by one. Line returns
+// the number of the array element, starting with 1.
+type jsonReader struct {
+	dec    *json.Decoder
+	record int
+}
+
+func newJSONReader(in io.Reader) (*jsonReader, error) {
+	dec := json.NewDecoder(in)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, apperror.NewBadRequestError("expected a JSON array of subscriptions")
+	}
+	return &jsonReader{dec: dec}, nil
+}
+
+func (r *jsonReader) Read() (subscription.Subscription, error) {
+	var s subscription.Subscription
+	if !r.dec.More() {
+		return s, io.EOF
+	}
+	r.record++
+
+	var item json.RawMessage
+	if err := r.dec.Decode(&item); err != nil {
+		// the rest of the array cannot be read
+		return s, fmt.Errorf("invalid JSON array: %w", err)
+	}
+	if err := json.Unmarshal(item, &s); err != nil {
+		return s, apperror.NewBadRequestError("invalid subscription: " + err.Error())
+	}
+	return s, nil
+}
+
+func (r *jsonReader) Line() int {
+	return r.record
+}
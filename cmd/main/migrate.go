@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pressly/goose/v3"
+	"os"
+	"text/tabwriter"
+	"time"
+	"tz1/migrations"
+	"tz1/pkg/client/postgresql"
+	"tz1/pkg/config"
+	"tz1/pkg/logging"
+)
+
+const migrateUsage = "migrate up|down|status|version"
+
+// runMigrate runs the migrate subcommand against the configured database.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("expected exactly one migrate action")
+	}
+	switch args[0] {
+	case "up", "down", "status", "version":
+	default:
+		return usageErrorf("unknown migrate action: %s", args[0])
+	}
+
+	pool, err := postgresql.NewClient(ctx, 6, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	provider, err := migrations.NewProvider(pool, cfg.Storage.MigrationsTable, logging.GetLogger())
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	switch args[0] {
+	case "up":
+		results, err := provider.Up(ctx)
+		printResults(results)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if result != nil {
+			printResults([]*goose.MigrationResult{result})
+		}
+		return err
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		return w.Flush()
+	case "version":
+		current, target, err := provider.GetVersions(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("current: %d\nlatest: %d\n", current, target)
+	}
+
+	return nil
+}
+
+// autoMigrate applies pending migrations on startup. The provider holds an
+// advisory lock for the whole run, so concurrent replicas wait for each other.
+func autoMigrate(ctx context.Context, pool *pgxpool.Pool, table string) error {
+	logger := logging.GetLogger()
+
+	provider, err := migrations.NewProvider(pool, table, logger)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	results, err := provider.Up(ctx)
+	for _, r := range results {
+		logger.Infof("applied migration %s in %s", r.Source.Path, r.Duration)
+	}
+	if err != nil {
+		return fmt.Errorf("auto migration failed: %w", err)
+	}
+
+	return nil
+}
+
+func printResults(results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r.Error != nil {
+			fmt.Printf("FAIL %s %s: %v\n", r.Direction, r.Source.Path, r.Error)
+			continue
+		}
+		fmt.Printf("OK   %s %s (%s)\n", r.Direction, r.Source.Path, r.Duration.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net"
+	"net/http"
+	"time"
+	"tz1/internal/health"
+	"tz1/internal/subscription"
+	sdb "tz1/internal/subscription/db"
+	"tz1/pkg/client/postgresql"
+	"tz1/pkg/config"
+	"tz1/pkg/logging"
+	"tz1/pkg/metrics"
+	"tz1/pkg/tracing"
+)
+
+const serveUsage = "serve"
+
+// runServe runs the HTTP API until ctx is cancelled.
+func runServe(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 0 {
+		return usageErrorf("unexpected arguments: %v", args)
+	}
+
+	logger := logging.GetLogger()
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Errorf("tracing shutdown failed: %v", err)
+		}
+	}()
+
+	postgreSQLClient, err := postgresql.NewClient(ctx, 6, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		logger.Info("close postgresql connection pool")
+		postgreSQLClient.Close()
+	}()
+
+	if cfg.Storage.AutoMigrate {
+		logger.Info("apply pending migrations")
+		if err = autoMigrate(ctx, postgreSQLClient, cfg.Storage.MigrationsTable); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("create router")
+	router := httprouter.New()
+
+	if err = metrics.RegisterPool(postgreSQLClient); err != nil {
+		return err
+	}
+	router.Handler(http.MethodGet, "/metrics", metrics.Handler())
+
+	logger.Info("register subscription handler")
+	sRep := sdb.NewRepository(postgreSQLClient, logger)
+	sHandler := subscription.NewHandler(sRep, logger)
+	sHandler.Register(router)
+
+	logger.Info("register health handler")
+	hHandler := health.NewHandler(postgreSQLClient, cfg.Storage.MigrationsTable, logger)
+	hHandler.Register(router)
+
+	return start(ctx, router, cfg)
+}
+
+func start(ctx context.Context, router *httprouter.Router, cfg *config.Config) error {
+	logger := logging.GetLogger()
+	logger.Info("start application")
+
+	logger.Info("listen tcp")
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%s", cfg.Listen.BindIp, cfg.Listen.Port))
+	if err != nil {
+		return err
+	}
+	logger.Infof("server is listening port %s:%s", cfg.Listen.BindIp, cfg.Listen.Port)
+
+	server := &http.Server{
+		Handler:      logging.Middleware(router),
+		WriteTimeout: 15 * time.Second,
+		ReadTimeout:  15 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	logger.Infof("shutdown server, waiting up to %s for in-flight requests", cfg.Listen.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Listen.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	logger.Info("server stopped")
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"tz1/internal/subscription"
+	"tz1/pkg/config"
+)
+
+const sumUsage = "sum --from MM-YYYY --to MM-YYYY [--date-mode active_in|starts_in|ends_in] [--user id] [--service name] [--mode cash|accrual] [--group-by fields | --monthly]"
+
+// runSum prints the subscription cost for a period as JSON, in the same shape
+// as the /subscriptions/sum endpoints.
+func runSum(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("sum", sumUsage)
+	var filter subscription.SumFilter
+	fs.StringVar(&filter.From, "from", "", "first month of the period, MM-YYYY")
+	fs.StringVar(&filter.To, "to", "", "last month of the period, MM-YYYY")
+	fs.StringVar(&filter.DateMode, "date-mode", "", "active_in, starts_in or ends_in (default active_in)")
+	fs.StringVar(&filter.User, "user", "", "only subscriptions of this user id")
+	fs.StringVar(&filter.Service, "service", "", "only subscriptions of this service")
+	fs.StringVar(&filter.Mode, "mode", "", "cash or accrual (default cash)")
+	groupBy := fs.String("group-by", "", "comma separated grouping: service_name, user_id")
+	monthly := fs.Bool("monthly", false, "break the sum down by month")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments: %v", fs.Args())
+	}
+	if filter.From == "" || filter.To == "" {
+		return usageErrorf("--from and --to are required")
+	}
+	if *groupBy != "" && *monthly {
+		return usageErrorf("--group-by and --monthly cannot be combined")
+	}
+
+	repository, closeRepository, err := openRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+
+	switch {
+	case *monthly:
+		months, err := repository.GetMonthlySum(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return writeJSON(subscription.MonthlySumResult{Months: months})
+	case *groupBy != "":
+		groups, err := repository.GetGroupedSum(ctx, filter, strings.Split(*groupBy, ","))
+		if err != nil {
+			return err
+		}
+		return writeJSON(subscription.GroupSumResult{Groups: groups})
+	default:
+		sum, err := repository.GetSum(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return writeJSON(subscription.SumResult{Sum: sum})
+	}
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"time"
+	"tz1/migrations"
+	"tz1/pkg/apperror"
+	"tz1/pkg/client/postgresql"
+	"tz1/pkg/handlers"
+	"tz1/pkg/logging"
+)
+
+const (
+	healthzURL = "/healthz"
+	readyzURL  = "/readyz"
+
+	checkTimeout = 2 * time.Second
+
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+type handler struct {
+	logger          *logging.Logger
+	client          postgresql.Client
+	migrationsTable string
+}
+
+func NewHandler(client postgresql.Client, migrationsTable string, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		client:          client,
+		migrationsTable: migrationsTable,
+		logger:          logger,
+	}
+}
+
+type Result struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+type Check struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	handlers.Route(router, http.MethodGet, healthzURL, apperror.Middleware(h.Healthz))
+	handlers.Route(router, http.MethodGet, readyzURL, apperror.Middleware(h.Readyz))
+}
+
+// Healthz reports that the process is alive and serving requests.
+func (h *handler) Healthz(w http.ResponseWriter, r *http.Request) error {
+	return write(w, http.StatusOK, Result{Status: statusOK})
+}
+
+// Readyz reports whether the service can handle requests: Postgres is
+// reachable and its schema is at the migration version the binary expects.
+func (h *handler) Readyz(w http.ResponseWriter, r *http.Request) error {
+	result := Result{
+		Status: statusOK,
+		Checks: map[string]Check{
+			"postgres":   h.check(r.Context(), h.client.Ping),
+			"migrations": h.check(r.Context(), h.checkMigrations),
+		},
+	}
+
+	status := http.StatusOK
+	for name, c := range result.Checks {
+		if c.Status != statusOK {
+			logging.FromContext(r.Context(), h.logger).Warnf("readiness check %s failed: %s", name, c.Message)
+			result.Status = statusFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	return write(w, status, result)
+}
+
+func (h *handler) check(ctx context.Context, fn func(ctx context.Context) error) Check {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	c := Check{Status: statusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		c.Status = statusFail
+		c.Message = err.Error()
+	}
+	return c
+}
+
+func (h *handler) checkMigrations(ctx context.Context) error {
+	expected, err := migrations.Version()
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf(`
+		SELECT COALESCE(MAX(version_id), 0)
+		FROM %s
+		WHERE is_applied
+	`, pgx.Identifier(strings.Split(h.migrationsTable, ".")).Sanitize())
+
+	var version int64
+	if err = h.client.QueryRow(ctx, q).Scan(&version); err != nil {
+		return err
+	}
+	if version != expected {
+		return fmt.Errorf("database schema version %d does not match expected %d", version, expected)
+	}
+	return nil
+}
+
+func write(w http.ResponseWriter, status int, result Result) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(resultBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package subscription
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"tz1/pkg/apperror"
+)
+
+// CSVHeader lists the columns written by CSVWriter. CSVReader accepts them in
+// any order, ignores current_price and requires all but id, billing_period and
+// end_date.
+var CSVHeader = []string{"id", "service_name", "price", "current_price", "billing_period", "user_id", "start_date", "end_date"}
+
+var requiredCSVColumns = []string{"service_name", "price", "user_id", "start_date"}
+
+// CSVWriter writes subscriptions as CSV rows under CSVHeader.
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter writes the header row and returns a writer for the records.
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return nil, err
+	}
+	return &CSVWriter{w: cw}, nil
+}
+
+func (c *CSVWriter) Write(s Subscription) error {
+	return c.w.Write([]string{
+		s.ID,
+		s.ServiceName,
+		strconv.FormatUint(uint64(s.Price), 10),
+		strconv.FormatUint(uint64(s.CurrentPrice), 10),
+		s.BillingPeriod,
+		s.User,
+		s.StartDate,
+		s.EndDate,
+	})
+}
+
+// Flush writes any buffered rows and reports the first write error.
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// CSVReader reads subscriptions from CSV with a header row.
+type CSVReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+// NewCSVReader reads and checks the header row.
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, apperror.NewBadRequestError("csv header is missing")
+	}
+	if err != nil {
+		return nil, apperror.NewBadRequestError(fmt.Sprintf("invalid csv header: %s", err))
+	}
+
+	known := make(map[string]bool, len(CSVHeader))
+	for _, name := range CSVHeader {
+		known[name] = true
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, apperror.NewBadRequestError(fmt.Sprintf("unknown csv column: %s", name))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, apperror.NewBadRequestError(fmt.Sprintf("duplicate csv column: %s", name))
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, apperror.NewBadRequestError(fmt.Sprintf("missing csv column: %s", name))
+		}
+	}
+
+	return &CSVReader{r: cr, columns: columns, line: 1}, nil
+}
+
+// Read returns the next subscription, or io.EOF after the last row. A row
+// that cannot be read is reported as an error and reading may continue with
+// the next one.
+func (c *CSVReader) Read() (Subscription, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line = parseErr.StartLine
+			return Subscription{}, apperror.NewBadRequestError(fmt.Sprintf("invalid csv row: %s", parseErr.Err))
+		}
+		return Subscription{}, err
+	}
+	c.line, _ = c.r.FieldPos(0)
+
+	s := Subscription{
+		ID:            c.value(record, "id"),
+		ServiceName:   c.value(record, "service_name"),
+		BillingPeriod: c.value(record, "billing_period"),
+		User:          c.value(record, "user_id"),
+		StartDate:     c.value(record, "start_date"),
+		EndDate:       c.value(record, "end_date"),
+	}
+	if price := c.value(record, "price"); price != "" {
+		p, err := strconv.ParseUint(price, 10, 32)
+		if err != nil {
+			var fields apperror.FieldErrors
+			fields.Add("price", apperror.FieldInvalidFormat, "price must be a non-negative integer")
+			return s, fields.Err()
+		}
+		s.Price = uint(p)
+	}
+
+	return s, nil
+}
+
+// Line returns the line number of the row returned by the last Read.
+func (c *CSVReader) Line() int {
+	return c.line
+}
+
+func (c *CSVReader) value(record []string, column string) string {
+	i, ok := c.columns[column]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
@@ -0,0 +1,184 @@
+package subscription
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"tz1/pkg/apperror"
+)
+
+const testUser = "1f0b8f5e-3c2a-4d6b-9a7e-2f1c3d4e5f60"
+
+// errorCode returns the code of an AppError, or an empty string.
+func errorCode(err error) string {
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ""
+}
+
+func TestNewCSVReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantCode string
+	}{
+		{name: "all columns", input: "id,service_name,price,current_price,billing_period,user_id,start_date,end_date\n"},
+		{name: "required columns in any order", input: "start_date,user_id,price,service_name\n"},
+		{name: "byte order mark", input: "\ufeffservice_name,price,user_id,start_date\n"},
+		{name: "case and spaces", input: "Service_Name, PRICE ,user_id,start_date\n"},
+		{name: "empty", input: "", wantCode: "US-000001"},
+		{name: "unknown column", input: "service_name,price,user_id,start_date,comment\n", wantCode: "US-000001"},
+		{name: "duplicate column", input: "service_name,price,price,user_id,start_date\n", wantCode: "US-000001"},
+		{name: "missing column", input: "service_name,user_id,start_date\n", wantCode: "US-000001"},
+		{name: "broken quotes", input: "\"service_name,price,user_id,start_date\n", wantCode: "US-000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCSVReader(strings.NewReader(tt.input))
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Errorf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCSVReaderRead(t *testing.T) {
+	type row struct {
+		line     int
+		want     Subscription
+		wantCode string
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		rows  []row
+	}{
+		{
+			name: "all columns",
+			input: "id,service_name,price,billing_period,user_id,start_date,end_date\n" +
+				"60601fee-2bf1-4721-ae6f-7636e79a0cba,Yandex Plus,400,annual," + testUser + ",07-2025,06-2026\n",
+			rows: []row{{line: 2, want: Subscription{
+				ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", ServiceName: "Yandex Plus", Price: 400, BillingPeriod: "annual",
+				User: testUser, StartDate: "07-2025", EndDate: "06-2026",
+			}}},
+		},
+		{
+			name:  "column order and spaces",
+			input: "\ufeffstart_date,user_id,price,service_name\n 07-2025 , " + testUser + ", 400, Yandex Plus \n",
+			rows:  []row{{line: 2, want: Subscription{ServiceName: "Yandex Plus", Price: 400, User: testUser, StartDate: "07-2025"}}},
+		},
+		{
+			name: "invalid rows do not stop reading",
+			input: "service_name,price,user_id,start_date\n" +
+				"Netflix,abc," + testUser + ",01-2025\n" +
+				"Netflix,100\n" +
+				"Netflix,-5," + testUser + ",01-2025\n" +
+				"Netflix,100," + testUser + ",01-2025\n",
+			rows: []row{
+				{line: 2, want: Subscription{ServiceName: "Netflix", User: testUser, StartDate: "01-2025"}, wantCode: "US-000005"},
+				{line: 3, wantCode: "US-000001"},
+				{line: 4, want: Subscription{ServiceName: "Netflix", User: testUser, StartDate: "01-2025"}, wantCode: "US-000005"},
+				{line: 5, want: Subscription{ServiceName: "Netflix", Price: 100, User: testUser, StartDate: "01-2025"}},
+			},
+		},
+		{
+			name: "empty price is left to validation",
+			input: "service_name,price,user_id,start_date\n" +
+				"Netflix,," + testUser + ",01-2025\n",
+			rows: []row{{line: 2, want: Subscription{ServiceName: "Netflix", User: testUser, StartDate: "01-2025"}}},
+		},
+		{
+			name: "line numbers after quoted line breaks and blank lines",
+			input: "service_name,price,user_id,start_date\n" +
+				"\"Yandex\nPlus\",400," + testUser + ",07-2025\n" +
+				"\n" +
+				"Netflix,100," + testUser + ",01-2025\n",
+			rows: []row{
+				{line: 2, want: Subscription{ServiceName: "Yandex\nPlus", Price: 400, User: testUser, StartDate: "07-2025"}},
+				{line: 5, want: Subscription{ServiceName: "Netflix", Price: 100, User: testUser, StartDate: "01-2025"}},
+			},
+		},
+		{
+			name: "bare quote",
+			input: "service_name,price,user_id,start_date\n" +
+				"Net\"flix,100," + testUser + ",01-2025\n" +
+				"Netflix,100," + testUser + ",02-2025\n",
+			rows: []row{
+				{line: 2, wantCode: "US-000001"},
+				{line: 3, want: Subscription{ServiceName: "Netflix", Price: 100, User: testUser, StartDate: "02-2025"}},
+			},
+		},
+		{
+			name:  "header only",
+			input: "service_name,price,user_id,start_date\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewCSVReader(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("header: %v", err)
+			}
+			for i, want := range tt.rows {
+				got, err := r.Read()
+				if code := errorCode(err); code != want.wantCode || (want.wantCode == "" && err != nil) {
+					t.Fatalf("row %d: error = %v (code %q), want code %q", i, err, code, want.wantCode)
+				}
+				if r.Line() != want.line {
+					t.Errorf("row %d: line = %d, want %d", i, r.Line(), want.line)
+				}
+				if want.wantCode != "US-000001" && !reflect.DeepEqual(got, want.want) {
+					t.Errorf("row %d: got %+v, want %+v", i, got, want.want)
+				}
+			}
+			if _, err := r.Read(); !errors.Is(err, io.EOF) {
+				t.Errorf("after the last row: error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	want := []Subscription{
+		{ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", ServiceName: "Yandex, \"Plus\"", Price: 400, CurrentPrice: 450, BillingPeriod: "monthly", User: testUser, StartDate: "07-2025"},
+		{ID: "7a3a9a1e-6c1b-4f7e-8f2d-9b0c1d2e3f40", ServiceName: "Netflix", Price: 999, CurrentPrice: 999, BillingPeriod: "annual", User: testUser, StartDate: "01-2025", EndDate: "12-2025"},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range want {
+		if err = w.Write(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCSVReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range want {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the current price is output only
+		s.CurrentPrice = 0
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("got %+v, want %+v", got, s)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
+	"tz1/pkg/metrics"
+)
+
+// CreateBatch validates every item first. A non-atomic batch then inserts the
+// valid items one by one, so a conflict only fails its own item. An atomic
+// batch inserts nothing unless every item is valid, and inserts in a single
+// transaction that is rolled back on the first failing item.
+func (r *repository) CreateBatch(ctx context.Context, subscriptions []subscription.Subscription, atomic bool) (subscription.BatchResult, error) {
+	defer metrics.ObserveQuery("CreateBatch", time.Now())
+
+	result := batchResult{BatchResult: subscription.BatchResult{Items: make([]subscription.BatchItem, len(subscriptions))}}
+	valid := make([]*pgSubscription, len(subscriptions))
+	for i := range subscriptions {
+		result.Items[i].Index = i
+		pgs := &pgSubscription{s: &subscriptions[i]}
+		if err := pgs.Validate(); err != nil {
+			result.fail(i, err)
+			continue
+		}
+		valid[i] = pgs
+	}
+
+	if !atomic {
+		for i, pgs := range valid {
+			if pgs == nil {
+				continue
+			}
+			if err := r.insert(ctx, r.client, pgs); err != nil {
+				if !result.fail(i, err) {
+					return subscription.BatchResult{}, err
+				}
+				continue
+			}
+			result.created(i, pgs.s)
+		}
+		return result.BatchResult, nil
+	}
+
+	if result.Failed > 0 {
+		result.rollBack()
+		return result.BatchResult, nil
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return subscription.BatchResult{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log(ctx).Errorf("batch rollback failed: %v", err)
+		}
+	}()
+
+	for i, pgs := range valid {
+		if err = r.insert(ctx, tx, pgs); err != nil {
+			if !result.fail(i, err) {
+				return subscription.BatchResult{}, err
+			}
+			result.rollBack()
+			return result.BatchResult, nil
+		}
+		result.created(i, pgs.s)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return subscription.BatchResult{}, r.pgError(ctx, err)
+	}
+
+	return result.BatchResult, nil
+}
+
+type batchResult struct {
+	subscription.BatchResult
+}
+
+func (b *batchResult) created(i int, s *subscription.Subscription) {
+	b.Items[i].Status = subscription.BatchStatusCreated
+	b.Items[i].Subscription = s
+	b.Created++
+}
+
+// fail records an item rejected by validation or by a constraint. It returns
+// false for other errors, which abort the whole batch.
+func (b *batchResult) fail(i int, err error) bool {
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+	b.Items[i].Status = subscription.BatchStatusInvalid
+	if errors.Is(err, apperror.ErrConflict) {
+		b.Items[i].Status = subscription.BatchStatusConflict
+	}
+	b.Items[i].Error = appErr
+	b.Failed++
+	return true
+}
+
+// rollBack marks every item that did not fail itself as not created.
+func (b *batchResult) rollBack() {
+	for i := range b.Items {
+		switch b.Items[i].Status {
+		case "", subscription.BatchStatusCreated:
+			b.Items[i].Status = subscription.BatchStatusRolledBack
+			b.Items[i].Subscription = nil
+		}
+	}
+	b.Created = 0
+}
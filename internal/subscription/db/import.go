@@ -0,0 +1,256 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"io"
+	"time"
+	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
+	"tz1/pkg/helper"
+	"tz1/pkg/metrics"
+)
+
+// maxImportErrors limits the row errors listed in the result, Failed still
+// counts all of them.
+const maxImportErrors = 1000
+
+var importColumns = []string{"line", "service_name", "price", "billing_period", "user", "start_date", "end_date"}
+
+// Import streams the valid rows into a temporary table with COPY and moves
+// them into the subscription table with a single INSERT. When a key appears
+// several times in the import the last row wins. A dry run does the same and
+// rolls the transaction back, so the counts include conflicts.
+func (r *repository) Import(ctx context.Context, rows subscription.ImportReader, options subscription.ImportOptions) (subscription.ImportResult, error) {
+	defer metrics.ObserveQuery("Import", time.Now())
+
+	result := subscription.ImportResult{DryRun: options.DryRun, Errors: make([]subscription.ImportRowError, 0)}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log(ctx).Errorf("import rollback failed: %v", err)
+		}
+	}()
+
+	// COPY lasts as long as the upload, which the caller bounds instead of the
+	// statement_timeout of the pool
+	q := "SET LOCAL statement_timeout = 0"
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	if _, err = tx.Exec(ctx, q); err != nil {
+		return result, r.pgError(ctx, err)
+	}
+
+	q = `
+		CREATE TEMPORARY TABLE subscription_import
+		(
+		    line           INT          NOT NULL,
+		    service_name   VARCHAR(100) NOT NULL,
+		    price          INT          NOT NULL,
+		    billing_period VARCHAR(16)  NOT NULL,
+		    "user"         TEXT         NOT NULL,
+		    start_date     DATE         NOT NULL,
+		    end_date       DATE
+		) ON COMMIT DROP
+	`
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	if _, err = tx.Exec(ctx, q); err != nil {
+		return result, r.pgError(ctx, err)
+	}
+
+	source := &importSource{rows: rows, result: &result}
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{"subscription_import"}, importColumns, source)
+	if err != nil {
+		if source.err != nil {
+			return result, source.err
+		}
+		return result, r.pgError(ctx, err)
+	}
+
+	onConflict := "DO NOTHING"
+	rejected := 0
+	if options.Update {
+		if rejected, err = r.rejectImportUpdates(ctx, tx, &result); err != nil {
+			return result, err
+		}
+		onConflict = `DO UPDATE SET price = EXCLUDED.price, billing_period = EXCLUDED.billing_period, end_date = EXCLUDED.end_date
+		WHERE (s.price, s.billing_period, s.end_date) IS DISTINCT FROM (EXCLUDED.price, EXCLUDED.billing_period, EXCLUDED.end_date)`
+	}
+	q = fmt.Sprintf(`
+		INSERT INTO public.subscription AS s
+		    (service_name, price, billing_period, "user", start_date, end_date)
+		SELECT DISTINCT ON ("user", service_name, start_date)
+		       service_name, price, billing_period, "user"::uuid, start_date, end_date
+		FROM subscription_import
+		ORDER BY "user", service_name, start_date, line DESC
+		ON CONFLICT ("user", service_name, start_date) %s
+		RETURNING (s.xmax = 0) AS inserted
+	`, onConflict)
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	inserted, err := tx.Query(ctx, q)
+	if err != nil {
+		return result, r.pgError(ctx, err)
+	}
+	defer inserted.Close()
+
+	for inserted.Next() {
+		var created bool
+		if err = inserted.Scan(&created); err != nil {
+			return result, err
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	if err = inserted.Err(); err != nil {
+		return result, r.pgError(ctx, err)
+	}
+	result.Skipped = int(copied) - rejected - result.Created - result.Updated
+
+	if options.DryRun {
+		return result, nil
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return result, r.pgError(ctx, err)
+	}
+
+	return result, nil
+}
+
+// rejectImportUpdates applies the Update rules to the imported rows that
+// would update a subscription: a started subscription keeps its initial price
+// and the end date cannot be earlier than the last price change. The rejected
+// rows are reported and their keys are removed from the import, so an earlier
+// row of the same key does not win instead. It returns the number of rejected
+// rows.
+func (r *repository) rejectImportUpdates(ctx context.Context, tx pgx.Tx, result *subscription.ImportResult) (int, error) {
+	q := `
+		WITH latest AS (
+		    SELECT DISTINCT ON ("user", service_name, start_date) *
+		    FROM subscription_import
+		    ORDER BY "user", service_name, start_date, line DESC
+		)
+		SELECT i.line, i.price, to_char(i.start_date, 'MM-YYYY'), to_char(i.end_date, 'MM-YYYY'), i.start_date, i.end_date,
+		       s.price, s.start_date < date_trunc('month', CURRENT_DATE)::date,
+		       (SELECT MIN(sp.effective_from) FROM public.subscription_price sp WHERE sp.subscription_id = s.id),
+		       (SELECT MAX(sp.effective_from) FROM public.subscription_price sp WHERE sp.subscription_id = s.id)
+		FROM latest i
+		JOIN public.subscription s ON s."user" = i."user"::uuid AND s.service_name = i.service_name AND s.start_date = i.start_date
+		WHERE (s.start_date < date_trunc('month', CURRENT_DATE)::date AND s.price <> i.price)
+		   OR i.end_date < (SELECT MAX(sp.effective_from) FROM public.subscription_price sp WHERE sp.subscription_id = s.id)
+		ORDER BY i.line
+		FOR UPDATE OF s
+	`
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := tx.Query(ctx, q)
+	if err != nil {
+		return 0, r.pgError(ctx, err)
+	}
+	defer rows.Close()
+
+	var lines []int
+	for rows.Next() {
+		var line int
+		var sub subscription.Subscription
+		var nullableEndDate pgtype.Text
+		var price uint
+		var started bool
+		var firstChange, lastChange pgtype.Date
+		pgs := pgSubscription{s: &sub}
+		err = rows.Scan(&line, &sub.Price, &sub.StartDate, &nullableEndDate, &pgs.pgStart, &pgs.pgEnd, &price, &started, &firstChange, &lastChange)
+		if err != nil {
+			return 0, err
+		}
+		sub.EndDate = nullableEndDate.String
+
+		var appErr *apperror.AppError
+		if errors.As(priceErrors(&pgs, price, started, firstChange, lastChange).Err(), &appErr) {
+			addImportError(result, line, appErr)
+			lines = append(lines, line)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, r.pgError(ctx, err)
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+
+	q = `
+		DELETE FROM subscription_import
+		WHERE ("user", service_name, start_date) IN (
+		    SELECT "user", service_name, start_date FROM subscription_import WHERE line = ANY($1)
+		)
+	`
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	if _, err = tx.Exec(ctx, q, lines); err != nil {
+		return 0, r.pgError(ctx, err)
+	}
+
+	return len(lines), nil
+}
+
+// addImportError counts the failed row and lists it up to maxImportErrors.
+func addImportError(result *subscription.ImportResult, line int, appErr *apperror.AppError) {
+	result.Failed++
+	if len(result.Errors) < maxImportErrors {
+		result.Errors = append(result.Errors, subscription.ImportRowError{
+			Line:    line,
+			Message: appErr.Message,
+			Fields:  appErr.Fields,
+		})
+	}
+}
+
+// importSource feeds the valid rows to CopyFrom and records the invalid ones.
+type importSource struct {
+	rows   subscription.ImportReader
+	result *subscription.ImportResult
+	values []any
+	err    error
+}
+
+func (s *importSource) Next() bool {
+	for {
+		sub, err := s.rows.Read()
+		if errors.Is(err, io.EOF) {
+			return false
+		}
+		s.result.Rows++
+
+		pgs := pgSubscription{s: &sub}
+		if err == nil {
+			err = pgs.Validate()
+		}
+		if err == nil {
+			s.values = []any{s.rows.Line(), sub.ServiceName, sub.Price, sub.BillingPeriod, sub.User, pgs.pgStart, pgs.pgEnd}
+			return true
+		}
+
+		var appErr *apperror.AppError
+		if !errors.As(err, &appErr) {
+			// the input cannot be read any further
+			s.err = err
+			return false
+		}
+		addImportError(s.result, s.rows.Line(), appErr)
+	}
+}
+
+func (s *importSource) Values() ([]any, error) {
+	return s.values, nil
+}
+
+func (s *importSource) Err() error {
+	return s.err
+}
@@ -0,0 +1,383 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"strings"
+	"time"
+	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
+	"tz1/pkg/helper"
+	"tz1/pkg/metrics"
+)
+
+// currentPrice is the price in force in the current month: the latest price
+// change that is already effective, or the initial price. The list returns it
+// next to the initial price, and the price filters and sort use it.
+const currentPrice = `COALESCE((
+		SELECT sp.price
+		FROM public.subscription_price sp
+		WHERE sp.subscription_id = subscription.id AND sp.effective_from <= CURRENT_DATE
+		ORDER BY sp.effective_from DESC
+		LIMIT 1
+	), subscription.price)`
+
+// listColumns are the columns selected by listQuery. The raw start date
+// follows the formatted one because the cursor needs the day.
+const listColumns = `id, "user", service_name, price, ` + currentPrice + `, billing_period, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY'), start_date`
+
+// sortColumns maps the sort fields accepted by the list to their columns.
+var sortColumns = map[string]string{
+	"service_name":   "service_name",
+	"price":          currentPrice,
+	"billing_period": "billing_period",
+	"user_id":        `"user"`,
+	"start_date":     "start_date",
+	"end_date":       "end_date",
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// likeEscaper escapes the LIKE wildcards with the default escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetList returns a page of subscriptions together with the number of all
+// matching ones, counted by a window function in the same query.
+func (r *repository) GetList(ctx context.Context, limit int, offset int, filter subscription.ListFilter) (a []subscription.Subscription, total int, err error) {
+	defer metrics.ObserveQuery("GetList", time.Now())
+
+	qb, err := listQuery(listColumns+", COUNT(*) OVER ()", filter, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	orderBy, err := listOrder(filter.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	q, args, err := qb.OrderBy(orderBy...).Limit(uint64(limit)).Offset(uint64(offset)).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]subscription.Subscription, 0)
+
+	for rows.Next() {
+		s, _, err := scanListRow(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// an empty page past the end or of zero size has no row to carry the count
+	if len(subscriptions) == 0 && (offset > 0 || limit == 0) {
+		if total, err = r.count(ctx, filter); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return subscriptions, total, nil
+}
+
+func (r *repository) count(ctx context.Context, filter subscription.ListFilter) (total int, err error) {
+	qb, err := listQuery("COUNT(*)", filter, nil)
+	if err != nil {
+		return 0, err
+	}
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	if err = r.client.QueryRow(ctx, q, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// GetListAfter returns up to limit subscriptions following the cursor, or
+// from the start when after is nil, in (start_date, id) order. The returned
+// cursor points after the last subscription and is nil on the last page.
+// The cursor only follows the default order, so filter.Sort must be empty.
+func (r *repository) GetListAfter(ctx context.Context, limit int, after *subscription.Cursor, filter subscription.ListFilter) (a []subscription.Subscription, next *subscription.Cursor, err error) {
+	defer metrics.ObserveQuery("GetListAfter", time.Now())
+
+	if len(filter.Sort) > 0 {
+		return nil, nil, apperror.NewBadRequestError("sort cannot be combined with cursor pagination")
+	}
+	qb, err := listQuery(listColumns, filter, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	// one extra row tells whether there is a next page
+	q, args, err := qb.OrderBy("start_date ASC", "id ASC").Limit(uint64(limit + 1)).ToSql()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]subscription.Subscription, 0, limit)
+	var last pgtype.Date
+
+	for rows.Next() {
+		if len(subscriptions) == limit {
+			next = &subscription.Cursor{StartDate: last.Time.Format(time.DateOnly), ID: subscriptions[limit-1].ID}
+			break
+		}
+
+		s, start, err := scanListRow(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		subscriptions = append(subscriptions, s)
+		last = start
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return subscriptions, next, nil
+}
+
+// Export calls fn for every subscription matching the GetList filters, in the
+// same order, as the rows arrive from the server instead of loading them all.
+func (r *repository) Export(ctx context.Context, filter subscription.ListFilter, fn func(subscription.Subscription) error) error {
+	defer metrics.ObserveQuery("Export", time.Now())
+
+	qb, err := listQuery(listColumns, filter, nil)
+	if err != nil {
+		return err
+	}
+	orderBy, err := listOrder(filter.Sort)
+	if err != nil {
+		return err
+	}
+	q, args, err := qb.OrderBy(orderBy...).ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log(ctx).Errorf("export rollback failed: %v", err)
+		}
+	}()
+
+	// the query runs as long as the client keeps reading, so it is not
+	// limited by the statement_timeout of the pool
+	if _, err = tx.Exec(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+		return r.pgError(ctx, err)
+	}
+	if _, err = tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return r.pgError(ctx, err)
+	}
+
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := tx.Query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, _, err := scanListRow(rows)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(s); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return r.pgError(ctx, err)
+	}
+
+	return nil
+}
+
+// scanListRow scans a row of listColumns followed by the extra columns.
+func scanListRow(rows pgx.Rows, extra ...any) (s subscription.Subscription, start pgtype.Date, err error) {
+	var nullableEndDate pgtype.Text
+
+	dest := append([]any{&s.ID, &s.User, &s.ServiceName, &s.Price, &s.CurrentPrice, &s.BillingPeriod, &s.StartDate, &nullableEndDate, &start}, extra...)
+	err = rows.Scan(dest...)
+	if err != nil {
+		return s, start, err
+	}
+
+	if nullableEndDate.Valid {
+		s.EndDate = nullableEndDate.String
+	}
+
+	return s, start, nil
+}
+
+// listQuery selects the columns of the subscriptions matching the filter and
+// following the keyset cursor when after is set. Invalid filter values are
+// reported as bad requests.
+func listQuery(columns string, f subscription.ListFilter, after *subscription.Cursor) (sq.SelectBuilder, error) {
+	qb := psql.Select(columns).From("public.subscription")
+
+	from, err := parseFilterDate("from", f.From)
+	if err != nil {
+		return qb, err
+	}
+	to, err := parseFilterDate("to", f.To)
+	if err != nil {
+		return qb, err
+	}
+	if from.Valid && to.Valid && from.Time.After(to.Time) {
+		return qb, apperror.NewBadRequestError(fmt.Sprintf("end date (%s) cannot be earlier than start (%s)", to.Time.Format("01-2006"), from.Time.Format("01-2006")))
+	}
+	switch f.DateMode {
+	case "", subscription.DateModeStartsIn:
+		if from.Valid {
+			qb = qb.Where(sq.GtOrEq{"start_date": from})
+		}
+		if to.Valid {
+			qb = qb.Where(sq.LtOrEq{"start_date": to})
+		}
+	case subscription.DateModeActiveIn:
+		// matches the GiST index on the subscription range, a missing bound
+		// leaves the window open
+		if from.Valid || to.Valid {
+			qb = qb.Where("daterange(start_date, end_date, '[]') && daterange(?::date, ?::date, '[]')", from, to)
+		}
+	case subscription.DateModeEndsIn:
+		if from.Valid {
+			qb = qb.Where(sq.GtOrEq{"end_date": from})
+		}
+		if to.Valid {
+			qb = qb.Where(sq.LtOrEq{"end_date": to})
+		}
+	default:
+		return qb, apperror.NewBadRequestError(fmt.Sprintf("invalid date_mode: %s", f.DateMode))
+	}
+
+	for _, user := range f.Users {
+		if !helper.IsValidUUID(user) {
+			return qb, apperror.NewBadRequestError(fmt.Sprintf("invalid subscription User: %s", user))
+		}
+	}
+	if len(f.Users) > 0 {
+		qb = qb.Where(sq.Eq{`"user"`: f.Users})
+	}
+	if len(f.Services) > 0 {
+		qb = qb.Where(sq.Eq{"service_name": f.Services})
+	}
+	if f.ServicePrefix != "" {
+		qb = qb.Where(sq.ILike{"service_name": likeEscaper.Replace(f.ServicePrefix) + "%"})
+	}
+
+	if f.PriceMin != nil && f.PriceMax != nil && *f.PriceMin > *f.PriceMax {
+		return qb, apperror.NewBadRequestError("price_min cannot be greater than price_max")
+	}
+	if f.PriceMin != nil {
+		qb = qb.Where(sq.GtOrEq{currentPrice: *f.PriceMin})
+	}
+	if f.PriceMax != nil {
+		qb = qb.Where(sq.LtOrEq{currentPrice: *f.PriceMax})
+	}
+
+	activeAt, err := parseFilterDate("active_at", f.ActiveAt)
+	if err != nil {
+		return qb, err
+	}
+	if activeAt.Valid {
+		qb = qb.Where(sq.LtOrEq{"start_date": activeAt}).
+			Where(sq.Or{sq.Eq{"end_date": nil}, sq.GtOrEq{"end_date": activeAt}})
+	}
+
+	switch f.Status {
+	case "":
+	case subscription.StatusActive:
+		qb = qb.Where("(end_date IS NULL OR end_date >= date_trunc('month', CURRENT_DATE)::date)")
+	case subscription.StatusEnded:
+		qb = qb.Where("end_date < date_trunc('month', CURRENT_DATE)::date")
+	default:
+		return qb, apperror.NewBadRequestError(fmt.Sprintf("invalid status: %s", f.Status))
+	}
+
+	if after != nil {
+		afterDate, err := time.Parse(time.DateOnly, after.StartDate)
+		if err != nil || !helper.IsValidUUID(after.ID) {
+			return qb, apperror.NewBadRequestError("invalid cursor")
+		}
+		qb = qb.Where("(start_date, id) > (?::date, ?::uuid)", pgtype.Date{Time: afterDate, Valid: true}, after.ID)
+	}
+
+	return qb, nil
+}
+
+// listOrder translates the sort fields into ORDER BY items. The ID breaks
+// ties so that pages never overlap.
+func listOrder(sort []string) ([]string, error) {
+	if len(sort) == 0 {
+		return []string{"start_date ASC", "id ASC"}, nil
+	}
+
+	orderBy := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			field, direction = field[1:], "DESC"
+		}
+		column, ok := sortColumns[field]
+		if !ok {
+			return nil, apperror.NewBadRequestError(fmt.Sprintf("invalid sort field: %s", field))
+		}
+		orderBy = append(orderBy, fmt.Sprintf("%s %s", column, direction))
+	}
+
+	return append(orderBy, "id ASC"), nil
+}
+
+func parseFilterDate(name, value string) (pgtype.Date, error) {
+	if value == "" {
+		return pgtype.Date{}, nil
+	}
+	date, err := helper.ParsePgDate(value)
+	if err != nil {
+		return date, apperror.NewBadRequestError(fmt.Sprintf("invalid %s date %s, expected MM-YYYY", name, value))
+	}
+	return date, nil
+}
@@ -0,0 +1,240 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"tz1/internal/subscription"
+)
+
+func TestListQuery(t *testing.T) {
+	const user = "1f0b8f5e-3c2a-4d6b-9a7e-2f1c3d4e5f60"
+
+	tests := []struct {
+		name      string
+		filter    subscription.ListFilter
+		after     *subscription.Cursor
+		wantWhere string
+		wantArgs  []interface{}
+		wantCode  string
+	}{
+		{
+			name: "no filter",
+		},
+		{
+			name:      "cursor",
+			after:     &subscription.Cursor{StartDate: "2025-07-01", ID: user},
+			wantWhere: "(start_date, id) > ($1::date, $2::uuid)",
+		},
+		{
+			name:      "cursor after filters",
+			filter:    subscription.ListFilter{From: "01-2025"},
+			after:     &subscription.Cursor{StartDate: "2025-07-01", ID: user},
+			wantWhere: "start_date >= $1 AND (start_date, id) > ($2::date, $3::uuid)",
+		},
+		{
+			name:     "cursor with a bad date",
+			after:    &subscription.Cursor{StartDate: "07-2025", ID: user},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "cursor with a bad id",
+			after:    &subscription.Cursor{StartDate: "2025-07-01", ID: "42"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "empty cursor",
+			after:    &subscription.Cursor{},
+			wantCode: "US-000001",
+		},
+		{
+			name:      "period",
+			filter:    subscription.ListFilter{From: "01-2025", To: "12-2025"},
+			wantWhere: "start_date >= $1 AND start_date <= $2",
+		},
+		{
+			name:     "to before from",
+			filter:   subscription.ListFilter{From: "12-2025", To: "01-2025"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad from",
+			filter:   subscription.ListFilter{From: "2025-01"},
+			wantCode: "US-000001",
+		},
+		{
+			name:      "users and services",
+			filter:    subscription.ListFilter{Users: []string{user}, Services: []string{"Netflix", "Yandex Plus"}},
+			wantWhere: `"user" IN ($1) AND service_name IN ($2,$3)`,
+			wantArgs:  []interface{}{user, "Netflix", "Yandex Plus"},
+		},
+		{
+			name:     "bad user",
+			filter:   subscription.ListFilter{Users: []string{user, "42"}},
+			wantCode: "US-000001",
+		},
+		{
+			name:      "service prefix",
+			filter:    subscription.ListFilter{ServicePrefix: "Yandex"},
+			wantWhere: "service_name ILIKE $1",
+			wantArgs:  []interface{}{"Yandex%"},
+		},
+		{
+			name:      "service prefix with LIKE wildcards",
+			filter:    subscription.ListFilter{ServicePrefix: `50%_off\`},
+			wantWhere: "service_name ILIKE $1",
+			wantArgs:  []interface{}{`50\%\_off\\%`},
+		},
+		{
+			name:      "price range",
+			filter:    subscription.ListFilter{PriceMin: uintPtr(100), PriceMax: uintPtr(300)},
+			wantWhere: currentPrice + " >= $1 AND " + currentPrice + " <= $2",
+			wantArgs:  []interface{}{uint(100), uint(300)},
+		},
+		{
+			name:      "equal price bounds",
+			filter:    subscription.ListFilter{PriceMin: uintPtr(300), PriceMax: uintPtr(300)},
+			wantWhere: currentPrice + " >= $1 AND " + currentPrice + " <= $2",
+		},
+		{
+			name:     "price min greater than max",
+			filter:   subscription.ListFilter{PriceMin: uintPtr(300), PriceMax: uintPtr(100)},
+			wantCode: "US-000001",
+		},
+		{
+			name:      "active at",
+			filter:    subscription.ListFilter{ActiveAt: "03-2025"},
+			wantWhere: "start_date <= $1 AND (end_date IS NULL OR end_date >= $2)",
+		},
+		{
+			name:     "bad active at",
+			filter:   subscription.ListFilter{ActiveAt: "13-2025"},
+			wantCode: "US-000001",
+		},
+		{
+			name:      "active",
+			filter:    subscription.ListFilter{Status: subscription.StatusActive},
+			wantWhere: "(end_date IS NULL OR end_date >= date_trunc('month', CURRENT_DATE)::date)",
+		},
+		{
+			name:      "ended",
+			filter:    subscription.ListFilter{Status: subscription.StatusEnded},
+			wantWhere: "end_date < date_trunc('month', CURRENT_DATE)::date",
+		},
+		{
+			name:     "bad status",
+			filter:   subscription.ListFilter{Status: "paused"},
+			wantCode: "US-000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb, err := listQuery("id", tt.filter, tt.after)
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			q, args, err := qb.ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "SELECT id FROM public.subscription"
+			if tt.wantWhere != "" {
+				want += " WHERE " + tt.wantWhere
+			}
+			if q != want {
+				t.Errorf("query:\n got %s\nwant %s", q, want)
+			}
+			if tt.wantArgs != nil && !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		sort     []string
+		want     []string
+		wantCode string
+	}{
+		{name: "default", want: []string{"start_date ASC", "id ASC"}},
+		{name: "ascending", sort: []string{"service_name"}, want: []string{"service_name ASC", "id ASC"}},
+		{name: "descending", sort: []string{"-end_date"}, want: []string{"end_date DESC", "id ASC"}},
+		{name: "several fields", sort: []string{"user_id", "-start_date"}, want: []string{`"user" ASC`, "start_date DESC", "id ASC"}},
+		{name: "price in force", sort: []string{"-price"}, want: []string{currentPrice + " DESC", "id ASC"}},
+		{name: "unknown field", sort: []string{"service_name", "id"}, wantCode: "US-000001"},
+		{name: "column name instead of field", sort: []string{"user"}, wantCode: "US-000001"},
+		{name: "empty field", sort: []string{""}, wantCode: "US-000001"},
+		{name: "only a minus", sort: []string{"-"}, wantCode: "US-000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listOrder(tt.sort)
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+			}
+			if tt.wantCode == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func TestGetListPriceInForce(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	user := newTestUser(t, r)
+
+	raised := subscription.Subscription{ServiceName: "raised", Price: 299, User: user, StartDate: "01-2024"}
+	future := subscription.Subscription{ServiceName: "future raise", Price: 299, User: user, StartDate: "01-2024"}
+	for _, s := range []*subscription.Subscription{&raised, &future} {
+		if err := r.Create(ctx, s); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if err := r.AddPrice(ctx, raised.ID, &subscription.Price{Price: 399, EffectiveFrom: "06-2024"}); err != nil {
+		t.Fatalf("add price: %v", err)
+	}
+	if err := r.AddPrice(ctx, future.ID, &subscription.Price{Price: 499, EffectiveFrom: "01-2099"}); err != nil {
+		t.Fatalf("add price: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter subscription.ListFilter
+		want   []string
+	}{
+		{name: "price max", filter: subscription.ListFilter{PriceMax: uintPtr(300)}, want: []string{"future raise: 299"}},
+		{name: "price min", filter: subscription.ListFilter{PriceMin: uintPtr(300)}, want: []string{"raised: 399"}},
+		{name: "sort by price", filter: subscription.ListFilter{Sort: []string{"-price"}}, want: []string{"raised: 399", "future raise: 299"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Users = []string{user}
+			items, total, err := r.GetList(ctx, 10, 0, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(items))
+			for _, s := range items {
+				got = append(got, fmt.Sprintf("%s: %d", s.ServiceName, s.CurrentPrice))
+			}
+			if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+				t.Errorf("got %q (total %d), want %q", got, total, tt.want)
+			}
+		})
+	}
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"math"
+	"strings"
+	"time"
 	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
 	"tz1/pkg/client/postgresql"
 	"tz1/pkg/helper"
 	"tz1/pkg/logging"
+	"tz1/pkg/metrics"
+	"unicode/utf8"
 )
 
 type repository struct {
@@ -23,129 +31,114 @@ type pgSubscription struct {
 	pgEnd   pgtype.Date
 }
 
+const (
+	// maxServiceNameLength and maxPrice follow the subscription table columns.
+	maxServiceNameLength = 100
+	maxPrice             = math.MaxInt32
+)
+
+// Validate checks the whole record and reports every invalid field at once.
 func (pgs *pgSubscription) Validate() error {
+	var fields apperror.FieldErrors
 	var err error
-	if pgs.s.StartDate != "" {
-		pgs.pgStart, err = helper.ParsePgDate(pgs.s.StartDate)
-		if err != nil {
-			return err
-		}
+
+	if pgs.s.ID != "" && !helper.IsValidUUID(pgs.s.ID) {
+		fields.Add("id", apperror.FieldInvalidFormat, fmt.Sprintf("invalid subscription ID: %s", pgs.s.ID))
 	}
-	if pgs.s.EndDate != "" {
-		pgs.pgEnd, err = helper.ParsePgDate(pgs.s.EndDate)
-		if err != nil {
-			return err
-		}
+	if strings.TrimSpace(pgs.s.ServiceName) == "" {
+		fields.Add("service_name", apperror.FieldRequired, "service name is required")
+	} else if utf8.RuneCountInString(pgs.s.ServiceName) > maxServiceNameLength {
+		fields.Add("service_name", apperror.FieldTooLong, fmt.Sprintf("service name cannot be longer than %d characters", maxServiceNameLength))
 	}
-	if pgs.s.User != "" && !helper.IsValidUUID(pgs.s.User) {
-		err = fmt.Errorf("invalid subscription User: %s", pgs.s.User)
-		return err
+	validatePrice(&fields, "price", pgs.s.Price)
+	if pgs.s.BillingPeriod == "" {
+		pgs.s.BillingPeriod = subscription.BillingPeriodMonthly
 	}
-	if pgs.s.ID != "" && !helper.IsValidUUID(pgs.s.ID) {
-		err = fmt.Errorf("invalid subscription ID: %s", pgs.s.ID)
-		return err
+	switch pgs.s.BillingPeriod {
+	case subscription.BillingPeriodWeekly, subscription.BillingPeriodMonthly, subscription.BillingPeriodQuarterly, subscription.BillingPeriodAnnual:
+	default:
+		fields.Add("billing_period", apperror.FieldInvalidValue, fmt.Sprintf("invalid subscription billing period: %s", pgs.s.BillingPeriod))
+	}
+	if pgs.s.User == "" {
+		fields.Add("user_id", apperror.FieldRequired, "user ID is required")
+	} else if !helper.IsValidUUID(pgs.s.User) {
+		fields.Add("user_id", apperror.FieldInvalidFormat, fmt.Sprintf("invalid subscription User: %s", pgs.s.User))
+	}
+	if pgs.s.StartDate == "" {
+		fields.Add("start_date", apperror.FieldRequired, "start date is required")
+	} else if pgs.pgStart, err = helper.ParsePgDate(pgs.s.StartDate); err != nil {
+		fields.Add("start_date", apperror.FieldInvalidFormat, fmt.Sprintf("invalid start date %s, expected MM-YYYY", pgs.s.StartDate))
+	}
+	if pgs.s.EndDate != "" {
+		if pgs.pgEnd, err = helper.ParsePgDate(pgs.s.EndDate); err != nil {
+			fields.Add("end_date", apperror.FieldInvalidFormat, fmt.Sprintf("invalid end date %s, expected MM-YYYY", pgs.s.EndDate))
+		}
 	}
 	if pgs.pgStart.Valid && pgs.pgEnd.Valid && pgs.pgEnd.Time.Before(pgs.pgStart.Time) {
-		err = fmt.Errorf("end date (%s) cannot be earlier than start (%s)", pgs.pgEnd.Time.Format("01-2006"), pgs.pgStart.Time.Format("01-2006"))
-		return err
+		fields.Add("end_date", apperror.FieldOutOfRange, fmt.Sprintf("end date (%s) cannot be earlier than start (%s)", pgs.pgEnd.Time.Format("01-2006"), pgs.pgStart.Time.Format("01-2006")))
+	}
+
+	return fields.Err()
+}
+
+func validatePrice(fields *apperror.FieldErrors, field string, price uint) {
+	if price == 0 {
+		fields.Add(field, apperror.FieldOutOfRange, "price must be positive")
+	} else if price > maxPrice {
+		fields.Add(field, apperror.FieldOutOfRange, fmt.Sprintf("price cannot be greater than %d", maxPrice))
 	}
-	return nil
 }
 
 func (r *repository) Create(ctx context.Context, s *subscription.Subscription) error {
+	defer metrics.ObserveQuery("Create", time.Now())
+
 	pgSubscription := pgSubscription{s: s}
 	if err := pgSubscription.Validate(); err != nil {
-		r.logger.Error(err)
+		r.log(ctx).Error(err)
 		return err
 	}
 
-	q := `
+	return r.insert(ctx, r.client, &pgSubscription)
+}
+
+// querier is implemented by both the pool and a transaction.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, arguments ...any) pgx.Row
+}
+
+// insert stores a validated subscription and sets its generated ID.
+func (r *repository) insert(ctx context.Context, q querier, pgs *pgSubscription) error {
+	query := `
 		INSERT INTO public.subscription 
-		    (service_name, price, "user", start_date, end_date ) 
+		    (service_name, price, billing_period, "user", start_date, end_date ) 
 		VALUES 
-		       ($1, $2, $3, $4, $5) 
+		       ($1, $2, $3, $4, $5, $6) 
 		RETURNING id
 	`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
-	row := r.client.QueryRow(ctx, q, pgSubscription.s.ServiceName, pgSubscription.s.Price, pgSubscription.s.User, pgSubscription.pgStart, pgSubscription.pgEnd)
-	if err := row.Scan(&pgSubscription.s.ID); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Error(newErr)
-			return newErr
-		}
-		return err
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(query)))
+	row := q.QueryRow(ctx, query, pgs.s.ServiceName, pgs.s.Price, pgs.s.BillingPeriod, pgs.s.User, pgs.pgStart, pgs.pgEnd)
+	if err := row.Scan(&pgs.s.ID); err != nil {
+		return r.pgError(ctx, err)
 	}
 
 	return nil
 }
 
-func (r *repository) GetList(ctx context.Context, limit int, offset int, from string, to string, user string, service string) (a []subscription.Subscription, err error) {
-
-	whereSet := "WHERE "
-	fromDate, err := helper.ParsePgDate(from)
-	toDate, err := helper.ParsePgDate(to)
-	placeholder := 1
-	args := make([]interface{}, 0)
+func (r *repository) FindAll(ctx context.Context) (a []subscription.Subscription, err error) {
+	defer metrics.ObserveQuery("FindAll", time.Now())
 
 	q := `
-		SELECT id, "user", service_name, price, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')  
+		SELECT id, "user", service_name, price, billing_period, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')  
 		FROM public.subscription
 	`
-	if fromDate.Valid && toDate.Valid && fromDate.Time.Before(toDate.Time) {
-		q = fmt.Sprintf("%s \n\t\t%s(start_date between $%d AND $%d)", q, whereSet, placeholder, placeholder+1)
-		whereSet = "AND"
-		args = append(args, fromDate)
-		args = append(args, toDate)
-		placeholder += 2
-	} else if fromDate.Valid && toDate.Valid && fromDate.Time.Equal(toDate.Time) {
-		q = fmt.Sprintf("%s \n\t\t%s start_date = $%d", q, whereSet, placeholder)
-		args = append(args, toDate)
-		whereSet = "AND"
-		placeholder++
-	} else if fromDate.Valid && toDate.Valid && fromDate.Time.After(toDate.Time) {
-		err = fmt.Errorf("end date (%s) cannot be earlier than start (%s)", toDate.Time.Format("01-2006"), fromDate.Time.Format("01-2006"))
-		return nil, err
-	} else if fromDate.Valid && !toDate.Valid {
-		q = fmt.Sprintf("%s \n\t\t%s start_date >= $%d", q, whereSet, placeholder)
-		args = append(args, fromDate)
-		whereSet = "AND"
-		placeholder++
-	} else if !fromDate.Valid && toDate.Valid {
-		q = fmt.Sprintf("%s \n\t\t%s start_date <= $%d", q, whereSet, placeholder)
-		args = append(args, toDate)
-		whereSet = "AND"
-		placeholder++
-	}
-
-	if user != "" {
-		if helper.IsValidUUID(user) {
-			q = fmt.Sprintf("%s %s \"user\" = $%d", q, whereSet, placeholder)
-			args = append(args, user)
-			whereSet = "AND"
-			placeholder++
-		} else {
-			return nil, fmt.Errorf("invalid subscription User: %s", user)
-		}
-	}
-	if service != "" {
-		q = fmt.Sprintf("%s %s service_name = $%d", q, whereSet, placeholder)
-		args = append(args, service)
-		whereSet = "AND"
-		placeholder++
-	}
-	q = fmt.Sprintf("%s \n\t\tORDER BY start_date ASC", q)
-	q = fmt.Sprintf("%s \n\t\tLIMIT $%d OFFSET $%d;", q, placeholder, placeholder+1)
-	placeholder += 2
-	args = append(args, limit, offset)
-
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	q = q + ";"
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
 
-	rows, err := r.client.Query(ctx, q, args...)
+	rows, err := r.client.Query(ctx, q)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	subscriptions := make([]subscription.Subscription, 0)
 
@@ -154,7 +147,7 @@ func (r *repository) GetList(ctx context.Context, limit int, offset int, from st
 
 		var nullableEndDate pgtype.Text
 
-		err = rows.Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.StartDate, &nullableEndDate)
+		err = rows.Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.BillingPeriod, &s.StartDate, &nullableEndDate)
 		if err != nil {
 			return nil, err
 		}
@@ -173,182 +166,328 @@ func (r *repository) GetList(ctx context.Context, limit int, offset int, from st
 	return subscriptions, nil
 }
 
-func (r *repository) GetSum(ctx context.Context, from string, to string, user string, service string) (sum int64, err error) {
-	var nullableInt pgtype.Int8
-
-	fromDate, err := helper.ParsePgDate(from)
-	toDate, err := helper.ParsePgDate(to)
-
-	placeholder := 1
-	args := make([]interface{}, 0)
+func (r *repository) FindOne(ctx context.Context, id string) (subscription.Subscription, error) {
+	defer metrics.ObserveQuery("FindOne", time.Now())
 
 	q := `
-		SELECT SUM(price)  
-		FROM public.subscription
+		SELECT id, "user", service_name, price, billing_period, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')  
+		FROM public.subscription 
+		WHERE id = $1
 	`
-	if fromDate.Valid && toDate.Valid && fromDate.Time.Before(toDate.Time) {
-		q = fmt.Sprintf("%s \n\t\tWHERE (start_date between $1 AND $2)", q)
-		args = append(args, fromDate)
-		args = append(args, toDate)
-		placeholder = 3
-	} else if fromDate.Valid && toDate.Valid && fromDate.Time.Equal(toDate.Time) {
-		q = fmt.Sprintf("%s \n\t\tWHERE start_date = $%d", q, placeholder)
-		args = append(args, toDate)
-		placeholder++
-	} else if fromDate.Valid && toDate.Valid && fromDate.Time.After(toDate.Time) {
-		err = fmt.Errorf("end date (%s) cannot be earlier than start (%s)", toDate.Time.Format("01-2006"), fromDate.Time.Format("01-2006"))
-		return 0, err
-	} else if fromDate.Valid && !toDate.Valid {
-		q = fmt.Sprintf("%s \n\t\tWHERE start_date >= $%d", q, placeholder)
-		args = append(args, fromDate)
-		placeholder++
-	} else if !fromDate.Valid && toDate.Valid {
-		q = fmt.Sprintf("%s \n\t\tWHERE start_date <= $%d", q, placeholder)
-		args = append(args, toDate)
-		placeholder++
-	} else if !fromDate.Valid && !toDate.Valid {
-		err = fmt.Errorf("date range is not specified")
-		return 0, err
-	}
-
-	if user != "" {
-		if helper.IsValidUUID(user) {
-			q = fmt.Sprintf("%s AND \"user\" = $%d", q, placeholder)
-			args = append(args, user)
-			placeholder++
-		} else {
-			return sum, fmt.Errorf("invalid subscription User: %s", user)
-		}
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	var s subscription.Subscription
+	var nullableEndDate pgtype.Text
+	row := r.client.QueryRow(ctx, q, id)
+	err := row.Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.BillingPeriod, &s.StartDate, &nullableEndDate)
+	if err != nil {
+		return subscription.Subscription{}, r.pgError(ctx, err)
 	}
-	if service != "" {
-		q = fmt.Sprintf("%s AND service_name = $%d", q, placeholder)
-		args = append(args, service)
-		placeholder++
+
+	if nullableEndDate.Valid {
+		s.EndDate = nullableEndDate.String
 	}
-	q = q + ";"
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
-
-	row := r.client.QueryRow(ctx, q, args...)
-	if err := row.Scan(&nullableInt); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Error(newErr)
-			return 0, newErr
-		}
-		return 0, err
+
+	s.Prices, err = r.findPrices(ctx, s)
+	if err != nil {
+		return subscription.Subscription{}, err
 	}
 
-	return nullableInt.Int64, nil
+	return s, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) (a []subscription.Subscription, err error) {
+// findPrices returns the price timeline of the subscription starting with its
+// initial price.
+func (r *repository) findPrices(ctx context.Context, s subscription.Subscription) ([]subscription.Price, error) {
 	q := `
-		SELECT id, "user", service_name, price, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')  
-		FROM public.subscription
+		SELECT price, to_char(effective_from, 'MM-YYYY')
+		FROM public.subscription_price
+		WHERE subscription_id = $1
+		ORDER BY effective_from ASC
 	`
-	q = q + ";"
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
 
-	rows, err := r.client.Query(ctx, q)
+	rows, err := r.client.Query(ctx, q, s.ID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	subscriptions := make([]subscription.Subscription, 0)
+	prices := []subscription.Price{{Price: s.Price, EffectiveFrom: s.StartDate}}
 
 	for rows.Next() {
-		var s subscription.Subscription
+		var p subscription.Price
 
-		var nullableEndDate pgtype.Text
-
-		err = rows.Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.StartDate, &nullableEndDate)
+		err = rows.Scan(&p.Price, &p.EffectiveFrom)
 		if err != nil {
 			return nil, err
 		}
 
-		if nullableEndDate.Valid {
-			s.EndDate = nullableEndDate.String
-		}
-
-		subscriptions = append(subscriptions, s)
+		prices = append(prices, p)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return subscriptions, nil
+	return prices, nil
 }
 
-func (r *repository) FindOne(ctx context.Context, id string) (subscription.Subscription, error) {
+func (r *repository) AddPrice(ctx context.Context, id string, p *subscription.Price) error {
+	defer metrics.ObserveQuery("AddPrice", time.Now())
+
+	s, err := r.FindOne(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var fields apperror.FieldErrors
+	validatePrice(&fields, "price", p.Price)
+	effectiveFrom, err := helper.ParsePgDate(p.EffectiveFrom)
+	if err != nil {
+		fields.Add("effective_from", apperror.FieldInvalidFormat, fmt.Sprintf("invalid effective_from date %s, expected MM-YYYY", p.EffectiveFrom))
+	} else {
+		startDate, _ := helper.ParseDate(s.StartDate)
+		if !effectiveFrom.Time.After(startDate) {
+			fields.Add("effective_from", apperror.FieldOutOfRange, fmt.Sprintf("price change (%s) must be later than start (%s)", p.EffectiveFrom, s.StartDate))
+		}
+		if s.EndDate != "" {
+			endDate, _ := helper.ParseDate(s.EndDate)
+			if effectiveFrom.Time.After(endDate) {
+				fields.Add("effective_from", apperror.FieldOutOfRange, fmt.Sprintf("price change (%s) cannot be later than end (%s)", p.EffectiveFrom, s.EndDate))
+			}
+		}
+	}
+	if err = fields.Err(); err != nil {
+		r.log(ctx).Error(err)
+		return err
+	}
+
 	q := `
-		SELECT id, "user", service_name, price, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')  
-		FROM public.subscription 
+		INSERT INTO public.subscription_price 
+		    (subscription_id, price, effective_from) 
+		VALUES 
+		       ($1, $2, $3) 
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price
+	`
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	_, err = r.client.Exec(ctx, q, id, p.Price, effectiveFrom)
+	if err != nil {
+		return r.pgError(ctx, err)
+	}
+
+	return nil
+}
+
+// Update replaces the subscription. Once the subscription has started its
+// initial price is part of past sums, so it cannot be changed any more and
+// later prices are added with AddPrice instead. The new start and end dates
+// must still enclose the price changes.
+func (r *repository) Update(ctx context.Context, id string, s *subscription.Subscription) error {
+	defer metrics.ObserveQuery("Update", time.Now())
+
+	s.ID = id
+	pgSubscription := pgSubscription{s: s}
+	if err := pgSubscription.Validate(); err != nil {
+		r.log(ctx).Error(err)
+		return err
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log(ctx).Errorf("update rollback failed: %v", err)
+		}
+	}()
+
+	if err = r.update(ctx, tx, &pgSubscription); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return r.pgError(ctx, err)
+	}
+
+	return nil
+}
+
+// Patch reads the subscription, changes it with fn and saves it like Update.
+// The row stays locked from the read to the write, so concurrent patches of
+// different fields do not undo each other.
+func (r *repository) Patch(ctx context.Context, id string, fn func(s *subscription.Subscription) error) (subscription.Subscription, error) {
+	defer metrics.ObserveQuery("Patch", time.Now())
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return subscription.Subscription{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			r.log(ctx).Errorf("patch rollback failed: %v", err)
+		}
+	}()
+
+	q := `
+		SELECT id, "user", service_name, price, billing_period, to_char(start_date, 'MM-YYYY'), to_char(end_date, 'MM-YYYY')
+		FROM public.subscription
 		WHERE id = $1
+		FOR UPDATE
 	`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
 
 	var s subscription.Subscription
 	var nullableEndDate pgtype.Text
-	row := r.client.QueryRow(ctx, q, id)
-	err := row.Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.StartDate, &nullableEndDate)
+	err = tx.QueryRow(ctx, q, id).Scan(&s.ID, &s.User, &s.ServiceName, &s.Price, &s.BillingPeriod, &s.StartDate, &nullableEndDate)
 	if err != nil {
-		return subscription.Subscription{}, err
+		return subscription.Subscription{}, r.pgError(ctx, err)
 	}
-
 	if nullableEndDate.Valid {
 		s.EndDate = nullableEndDate.String
 	}
 
+	if err = fn(&s); err != nil {
+		return subscription.Subscription{}, err
+	}
+
+	s.ID = id
+	pgSubscription := pgSubscription{s: &s}
+	if err = pgSubscription.Validate(); err != nil {
+		r.log(ctx).Error(err)
+		return subscription.Subscription{}, err
+	}
+
+	if err = r.update(ctx, tx, &pgSubscription); err != nil {
+		return subscription.Subscription{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return subscription.Subscription{}, r.pgError(ctx, err)
+	}
+
 	return s, nil
 }
 
-func (r *repository) Update(ctx context.Context, id string, s *subscription.Subscription) error {
-	s.ID = id
-	pgSubscription := pgSubscription{s: s}
-	if err := pgSubscription.Validate(); err != nil {
-		r.logger.Error(err)
+// update checks the validated subscription against its stored price and
+// price changes and writes it in tx.
+func (r *repository) update(ctx context.Context, tx pgx.Tx, pgs *pgSubscription) error {
+	s := pgs.s
+
+	q := `
+		SELECT s.price, s.start_date < date_trunc('month', CURRENT_DATE)::date,
+		       (SELECT MIN(sp.effective_from) FROM public.subscription_price sp WHERE sp.subscription_id = s.id),
+		       (SELECT MAX(sp.effective_from) FROM public.subscription_price sp WHERE sp.subscription_id = s.id)
+		FROM public.subscription s
+		WHERE s.id = $1
+		FOR UPDATE
+	`
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	var price uint
+	var started bool
+	var firstChange, lastChange pgtype.Date
+	if err := tx.QueryRow(ctx, q, s.ID).Scan(&price, &started, &firstChange, &lastChange); err != nil {
+		return r.pgError(ctx, err)
+	}
+
+	if err := priceErrors(pgs, price, started, firstChange, lastChange).Err(); err != nil {
+		r.log(ctx).Error(err)
 		return err
 	}
 
-	q := `
+	q = `
 		UPDATE public.subscription 
 		SET service_name = $1,
 		    price = $2,
-		    "user" = $3,
-		    start_date = $4,
-		    end_date = $5
-		WHERE id = $6 
+		    billing_period = $3,
+		    "user" = $4,
+		    start_date = $5,
+		    end_date = $6
+		WHERE id = $7 
 		RETURNING id
 	`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
 
-	row := r.client.QueryRow(ctx, q, pgSubscription.s.ServiceName, pgSubscription.s.Price, pgSubscription.s.User, pgSubscription.pgStart, pgSubscription.pgEnd, pgSubscription.s.ID)
-
-	if err := row.Scan(&pgSubscription.s.ID); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Error(newErr)
-			return newErr
-		}
-		return err
+	row := tx.QueryRow(ctx, q, s.ServiceName, s.Price, s.BillingPeriod, s.User, pgs.pgStart, pgs.pgEnd, s.ID)
+	if err := row.Scan(&s.ID); err != nil {
+		return r.pgError(ctx, err)
 	}
 
 	return nil
 }
 
+// priceErrors checks a new version of a stored subscription against its
+// initial price and the dates of its price changes.
+func priceErrors(pgs *pgSubscription, price uint, started bool, firstChange, lastChange pgtype.Date) apperror.FieldErrors {
+	s := pgs.s
+
+	var fields apperror.FieldErrors
+	if started && s.Price != price {
+		fields.Add("price", apperror.FieldInvalidValue, fmt.Sprintf("initial price (%d) of a started subscription cannot be changed, add a price change instead", price))
+	}
+	if firstChange.Valid && !firstChange.Time.After(pgs.pgStart.Time) {
+		fields.Add("start_date", apperror.FieldOutOfRange, fmt.Sprintf("start date (%s) must be earlier than the first price change (%s)", s.StartDate, firstChange.Time.Format("01-2006")))
+	}
+	if lastChange.Valid && pgs.pgEnd.Valid && lastChange.Time.After(pgs.pgEnd.Time) {
+		fields.Add("end_date", apperror.FieldOutOfRange, fmt.Sprintf("end date (%s) cannot be earlier than the last price change (%s)", s.EndDate, lastChange.Time.Format("01-2006")))
+	}
+
+	return fields
+}
+
 func (r *repository) Delete(ctx context.Context, id string) error {
+	defer metrics.ObserveQuery("Delete", time.Now())
+
 	q := `
 		DELETE FROM public.subscription 
 	    WHERE id = $1
 	`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	tag, err := r.client.Exec(ctx, q, id)
+	if err != nil {
+		return r.pgError(ctx, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.NewNotFoundError(fmt.Sprintf("subscription %s not found", id))
+	}
+
+	return nil
+}
+
+// log returns the request-scoped logger from ctx, falling back to the
+// repository logger.
+func (r *repository) log(ctx context.Context) *logging.Logger {
+	return logging.FromContext(ctx, r.logger)
+}
+
+// pgError converts an error returned by the client into a domain error: a
+// missing row becomes not found, a unique violation becomes a conflict and
+// data or check constraint errors become validation failures.
+func (r *repository) pgError(ctx context.Context, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.NewNotFoundError("subscription not found")
+	}
 
-	_, err := r.client.Query(ctx, q, id)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	newErr := fmt.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState())
+	r.log(ctx).Error(newErr)
+
+	switch {
+	case pgErr.Code == pgerrcode.UniqueViolation:
+		return apperror.NewConflictError("subscription already exists for this user, service and start date", pgErr.Detail)
+	case pgErr.Code == pgerrcode.CheckViolation, pgerrcode.IsDataException(pgErr.Code):
+		return apperror.NewValidationError(pgErr.Message)
+	}
 
-	return err
+	return newErr
 }
 
 func NewRepository(client postgresql.Client, logger *logging.Logger) subscription.Repository {
@@ -0,0 +1,245 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+	"strings"
+	"time"
+	"tz1/internal/subscription"
+	"tz1/pkg/apperror"
+	"tz1/pkg/helper"
+	"tz1/pkg/metrics"
+)
+
+func (r *repository) GetSum(ctx context.Context, f subscription.SumFilter) (sum float64, err error) {
+	defer metrics.ObserveQuery("GetSum", time.Now())
+
+	var nullableSum pgtype.Float8
+
+	active, args, err := buildActiveMonths(f)
+	if err != nil {
+		return 0, err
+	}
+
+	q := fmt.Sprintf(`
+		WITH active AS (%s)
+		SELECT ROUND(SUM(amount), 2)::float8
+		FROM active;
+	`, active)
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	row := r.client.QueryRow(ctx, q, args...)
+	if err := row.Scan(&nullableSum); err != nil {
+		return 0, r.pgError(ctx, err)
+	}
+
+	return nullableSum.Float64, nil
+}
+
+func (r *repository) GetMonthlySum(ctx context.Context, f subscription.SumFilter) (a []subscription.MonthlySum, err error) {
+	defer metrics.ObserveQuery("GetMonthlySum", time.Now())
+
+	active, args, err := buildActiveMonths(f)
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf(`
+		WITH active AS (%s)
+		SELECT to_char(p.month, 'MM-YYYY'), COALESCE(ROUND(SUM(a.amount), 2), 0)::float8, COUNT(a.id)
+		FROM generate_series($1::date, $2::date, interval '1 month') AS p(month)
+		LEFT JOIN active a ON a.month = p.month
+		GROUP BY p.month
+		ORDER BY p.month;
+	`, active)
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := make([]subscription.MonthlySum, 0)
+
+	for rows.Next() {
+		var m subscription.MonthlySum
+
+		err = rows.Scan(&m.Month, &m.Sum, &m.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		months = append(months, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return months, nil
+}
+
+func (r *repository) GetGroupedSum(ctx context.Context, f subscription.SumFilter, groupBy []string) (a []subscription.GroupSum, err error) {
+	defer metrics.ObserveQuery("GetGroupedSum", time.Now())
+
+	active, args, err := buildActiveMonths(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groupBy) == 0 {
+		return nil, apperror.NewBadRequestError("group_by is not specified")
+	}
+	serviceColumn, userColumn := "''", "''"
+	group := make([]string, 0, len(groupBy))
+	for _, g := range groupBy {
+		switch g {
+		case "service_name":
+			serviceColumn = "service_name"
+			group = append(group, serviceColumn)
+		case "user_id":
+			userColumn = "\"user\"::text"
+			group = append(group, userColumn)
+		default:
+			return nil, apperror.NewBadRequestError(fmt.Sprintf("invalid group_by: %s", g))
+		}
+	}
+
+	q := fmt.Sprintf(`
+		WITH active AS (%s)
+		SELECT %s, %s, ROUND(SUM(amount), 2)::float8, COUNT(DISTINCT id)
+		FROM active
+		GROUP BY %s
+		ORDER BY 3 DESC, 1, 2;
+	`, active, serviceColumn, userColumn, strings.Join(group, ", "))
+	r.log(ctx).Trace(fmt.Sprintf("SQL Query: %s", helper.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]subscription.GroupSum, 0)
+
+	for rows.Next() {
+		var g subscription.GroupSum
+
+		err = rows.Scan(&g.ServiceName, &g.User, &g.Sum, &g.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// activeMonthsQuery expands every subscription matching the WHERE clause into
+// the months it is active inside the period $1..$2, one row per subscription
+// and month. Open-ended subscriptions run up to $2. The price is the one in
+// force in that month according to the price history, and amount is the part
+// of it that falls on the month in the requested sum mode.
+const activeMonthsQuery = `
+		SELECT id, "user", service_name, month, %s AS amount
+		FROM (
+		    SELECT s.id, s."user", s.service_name, s.billing_period, s.start_date, m.month,
+		           COALESCE((
+		               SELECT sp.price
+		               FROM public.subscription_price sp
+		               WHERE sp.subscription_id = s.id AND sp.effective_from <= m.month
+		               ORDER BY sp.effective_from DESC
+		               LIMIT 1
+		           ), s.price) AS price
+		    FROM public.subscription s
+		    CROSS JOIN LATERAL generate_series(
+		        GREATEST(s.start_date, $1::date),
+		        LEAST(COALESCE(s.end_date, $2::date), $2::date),
+		        interval '1 month'
+		    ) AS m(month)
+		    %s
+		) AS priced
+	`
+
+// amountExpressions hold the amount of a subscription price that falls on a
+// month per sum mode. Accrual spreads the price evenly over its billing period.
+// Cash charges it in the start month and every renewal month; weekly plans are
+// charged every 7 days from the start date.
+var amountExpressions = map[string]string{
+	subscription.SumModeAccrual: `price * CASE billing_period
+		        WHEN 'weekly' THEN 52.0 / 12
+		        WHEN 'quarterly' THEN 1.0 / 3
+		        WHEN 'annual' THEN 1.0 / 12
+		        ELSE 1
+		    END`,
+	subscription.SumModeCash: `price * CASE billing_period
+		        WHEN 'weekly' THEN ((month + interval '1 month')::date - start_date + 6) / 7 - (month::date - start_date + 6) / 7
+		        WHEN 'quarterly' THEN CASE WHEN ((EXTRACT(YEAR FROM month)::int - EXTRACT(YEAR FROM start_date)::int) * 12 + EXTRACT(MONTH FROM month)::int - EXTRACT(MONTH FROM start_date)::int) % 3 = 0 THEN 1 ELSE 0 END
+		        WHEN 'annual' THEN CASE WHEN EXTRACT(MONTH FROM month) = EXTRACT(MONTH FROM start_date) THEN 1 ELSE 0 END
+		        ELSE 1
+		    END`,
+}
+
+// buildActiveMonths returns activeMonthsQuery for the filter and its
+// arguments. The period bounds are always $1 and $2; a subscription matches
+// when it is active in at least one month of the period.
+func buildActiveMonths(f subscription.SumFilter) (string, []interface{}, error) {
+	fromDate, err := helper.ParsePgDate(f.From)
+	if err != nil {
+		return "", nil, apperror.NewBadRequestError(fmt.Sprintf("invalid from date: %s", f.From))
+	}
+	toDate, err := helper.ParsePgDate(f.To)
+	if err != nil {
+		return "", nil, apperror.NewBadRequestError(fmt.Sprintf("invalid to date: %s", f.To))
+	}
+	if toDate.Time.Before(fromDate.Time) {
+		return "", nil, apperror.NewBadRequestError(fmt.Sprintf("end date (%s) cannot be earlier than start (%s)", toDate.Time.Format("01-2006"), fromDate.Time.Format("01-2006")))
+	}
+
+	mode := f.Mode
+	if mode == "" {
+		mode = subscription.SumModeCash
+	}
+	amount, ok := amountExpressions[mode]
+	if !ok {
+		return "", nil, apperror.NewBadRequestError(fmt.Sprintf("invalid mode: %s", f.Mode))
+	}
+
+	args := []interface{}{fromDate, toDate}
+	placeholder := 3
+	var where string
+	switch f.DateMode {
+	case "", subscription.DateModeActiveIn:
+		// matches the GiST index on the subscription range
+		where = "WHERE daterange(s.start_date, s.end_date, '[]') && daterange($1::date, $2::date, '[]')"
+	case subscription.DateModeStartsIn:
+		where = "WHERE s.start_date BETWEEN $1 AND $2"
+	case subscription.DateModeEndsIn:
+		where = "WHERE s.end_date BETWEEN $1 AND $2"
+	default:
+		return "", nil, apperror.NewBadRequestError(fmt.Sprintf("invalid date_mode: %s", f.DateMode))
+	}
+
+	if f.User != "" {
+		if !helper.IsValidUUID(f.User) {
+			return "", nil, apperror.NewBadRequestError(fmt.Sprintf("invalid subscription User: %s", f.User))
+		}
+		where = fmt.Sprintf("%s AND s.\"user\" = $%d", where, placeholder)
+		args = append(args, f.User)
+		placeholder++
+	}
+	if f.Service != "" {
+		where = fmt.Sprintf("%s AND s.service_name = $%d", where, placeholder)
+		args = append(args, f.Service)
+		placeholder++
+	}
+
+	return fmt.Sprintf(activeMonthsQuery, amount, where), args, nil
+}
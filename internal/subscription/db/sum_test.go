@@ -0,0 +1,225 @@
+package subscription
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"tz1/internal/subscription"
+)
+
+func TestBuildActiveMonths(t *testing.T) {
+	const user = "1f0b8f5e-3c2a-4d6b-9a7e-2f1c3d4e5f60"
+
+	tests := []struct {
+		name     string
+		filter   subscription.SumFilter
+		wantCode string
+		wantArgs int
+		contains []string
+	}{
+		{
+			name:     "period",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025"},
+			wantArgs: 2,
+			contains: []string{"daterange(s.start_date, s.end_date, '[]') && daterange($1::date, $2::date, '[]')"},
+		},
+		{
+			name:     "single month",
+			filter:   subscription.SumFilter{From: "03-2025", To: "03-2025"},
+			wantArgs: 2,
+		},
+		{
+			name:     "user",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", User: user},
+			wantArgs: 3,
+			contains: []string{`s."user" = $3`},
+		},
+		{
+			name:     "user and service",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", User: user, Service: "Yandex Plus"},
+			wantArgs: 4,
+			contains: []string{`s."user" = $3`, "s.service_name = $4"},
+		},
+		{
+			name:     "service",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", Service: "Yandex Plus"},
+			wantArgs: 3,
+			contains: []string{"s.service_name = $3"},
+		},
+		{
+			name:     "accrual mode",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", Mode: subscription.SumModeAccrual},
+			wantArgs: 2,
+			contains: []string{"52.0 / 12"},
+		},
+		{
+			name:     "starts in",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", DateMode: subscription.DateModeStartsIn},
+			wantArgs: 2,
+			contains: []string{"s.start_date BETWEEN $1 AND $2"},
+		},
+		{
+			name:     "ends in",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", DateMode: subscription.DateModeEndsIn},
+			wantArgs: 2,
+			contains: []string{"s.end_date BETWEEN $1 AND $2"},
+		},
+		{
+			name:     "to before from",
+			filter:   subscription.SumFilter{From: "05-2025", To: "04-2025"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad from",
+			filter:   subscription.SumFilter{From: "2025-01", To: "12-2025"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad to month",
+			filter:   subscription.SumFilter{From: "01-2025", To: "13-2025"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "missing period",
+			filter:   subscription.SumFilter{},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad user",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", User: "not-a-uuid"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad mode",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", Mode: "lazy"},
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad date mode",
+			filter:   subscription.SumFilter{From: "01-2025", To: "12-2025", DateMode: "ever"},
+			wantCode: "US-000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, args, err := buildActiveMonths(tt.filter)
+			if tt.wantCode != "" {
+				if code := errorCode(err); code != tt.wantCode {
+					t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(args) != tt.wantArgs {
+				t.Errorf("got %d args, want %d", len(args), tt.wantArgs)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(q, s) {
+					t.Errorf("query does not contain %q:\n%s", s, q)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSum(t *testing.T) {
+	r := newTestRepository(t)
+
+	tests := []struct {
+		name          string
+		subscriptions []subscription.Subscription
+		from, to      string
+		want          float64
+		wantCode      string
+	}{
+		{
+			name:          "starts before from",
+			subscriptions: []subscription.Subscription{{Price: 100, StartDate: "06-2024", EndDate: "06-2025"}},
+			from:          "01-2025",
+			to:            "12-2025",
+			want:          600,
+		},
+		{
+			name:          "no end date runs to the end of the period",
+			subscriptions: []subscription.Subscription{{Price: 100, StartDate: "10-2025"}},
+			from:          "01-2025",
+			to:            "12-2025",
+			want:          300,
+		},
+		{
+			name:          "no end date started before from",
+			subscriptions: []subscription.Subscription{{Price: 400, StartDate: "03-2025"}},
+			from:          "09-2025",
+			to:            "12-2025",
+			want:          1600,
+		},
+		{
+			name:          "ends inside the period",
+			subscriptions: []subscription.Subscription{{Price: 100, StartDate: "01-2024", EndDate: "03-2025"}},
+			from:          "01-2025",
+			to:            "12-2025",
+			want:          300,
+		},
+		{
+			name:          "ended before the period",
+			subscriptions: []subscription.Subscription{{Price: 100, StartDate: "01-2024", EndDate: "12-2024"}},
+			from:          "01-2025",
+			to:            "12-2025",
+			want:          0,
+		},
+		{
+			name: "from equals to",
+			subscriptions: []subscription.Subscription{
+				{Price: 100, StartDate: "01-2025"},
+				{Price: 50, StartDate: "03-2025", EndDate: "03-2025"},
+				{Price: 70, StartDate: "04-2025"},
+			},
+			from: "03-2025",
+			to:   "03-2025",
+			want: 150,
+		},
+		{
+			name:     "to before from",
+			from:     "12-2025",
+			to:       "01-2025",
+			wantCode: "US-000001",
+		},
+		{
+			name:     "bad date",
+			from:     "2025-01",
+			to:       "12-2025",
+			wantCode: "US-000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			user := newTestUser(t, r)
+			for i, s := range tt.subscriptions {
+				s.User = user
+				s.ServiceName = "service " + string(rune('A'+i))
+				if err := r.Create(ctx, &s); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+			}
+
+			got, err := r.GetSum(ctx, subscription.SumFilter{From: tt.from, To: tt.to, User: user})
+			if tt.wantCode != "" {
+				if code := errorCode(err); code != tt.wantCode {
+					t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sum = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package subscription
+
+import (
+	"encoding/json"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"mime"
+	"strings"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+)
+
+// exportContentTypes maps the export formats to their media types, in the
+// order of preference when the Accept header allows several.
+var exportContentTypes = []struct {
+	format      string
+	contentType string
+}{
+	{ExportFormatCSV, "text/csv"},
+	{ExportFormatNDJSON, "application/x-ndjson"},
+	{ExportFormatXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+// exportFormat picks the format named by the format query value or else the
+// first acceptable media type of the Accept header. CSV is the default.
+func exportFormat(format, accept string) (name string, contentType string, ok bool) {
+	if format != "" {
+		for _, t := range exportContentTypes {
+			if t.format == format {
+				return t.format, t.contentType, true
+			}
+		}
+		return "", "", false
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for _, t := range exportContentTypes {
+			if t.contentType == mediaType {
+				return t.format, t.contentType, true
+			}
+		}
+	}
+
+	return exportContentTypes[0].format, exportContentTypes[0].contentType, true
+}
+
+// exporter writes subscriptions in one of the export formats. Close writes
+// whatever is still buffered, Abort drops it when the export fails. Either
+// one releases the exporter.
+type exporter interface {
+	Write(s Subscription) error
+	Close() error
+	Abort()
+}
+
+func newExporter(format string, w io.Writer) (exporter, error) {
+	switch format {
+	case ExportFormatNDJSON:
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	case ExportFormatXLSX:
+		e, err := newXLSXExporter(w)
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	default:
+		cw, err := NewCSVWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &csvExporter{cw}, nil
+	}
+}
+
+type csvExporter struct {
+	*CSVWriter
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+func (e *csvExporter) Abort() {}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(s Subscription) error {
+	return e.enc.Encode(s)
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+func (e *ndjsonExporter) Abort() {}
+
+// xlsxExporter writes rows with the excelize stream writer, which keeps them
+// in a temporary file instead of memory. The workbook can only be sent once
+// it is complete, so it is written to w on Close.
+type xlsxExporter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(file.GetSheetName(0))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	e := &xlsxExporter{w: w, file: file, stream: stream}
+	header := make([]interface{}, len(CSVHeader))
+	for i, name := range CSVHeader {
+		header[i] = name
+	}
+	if err = e.setRow(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *xlsxExporter) Write(s Subscription) error {
+	return e.setRow([]interface{}{s.ID, s.ServiceName, s.Price, s.CurrentPrice, s.BillingPeriod, s.User, s.StartDate, s.EndDate})
+}
+
+func (e *xlsxExporter) setRow(values []interface{}) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.stream.SetRow(cell, values)
+}
+
+func (e *xlsxExporter) Close() error {
+	defer e.file.Close()
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.w)
+}
+
+// Abort removes the temporary files of the workbook without sending it.
+func (e *xlsxExporter) Abort() {
+	e.file.Close()
+}
@@ -1,20 +1,46 @@
 package subscription
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 	"tz1/pkg/apperror"
 	"tz1/pkg/handlers"
 	"tz1/pkg/helper"
 	"tz1/pkg/logging"
+	"tz1/pkg/tracing"
 )
 
 const (
 	subscriptionsURL    = "/subscriptions"
+	batchURL            = "/subscriptions/batch"
+	importURL           = "/subscriptions/import"
+	exportURL           = "/subscriptions/export"
 	subscriptionURL     = "/subscription/:uuid"
+	pricesURL           = "/subscription/:uuid/prices"
 	subscriptionsSumURL = "/subscriptions/sum"
+	monthlySumURL       = "/subscriptions/sum/monthly"
+)
+
+const (
+	// maxBatchSize limits the number of subscriptions in one batch request.
+	maxBatchSize = 1000
+	// maxImportSize limits the size of an imported CSV file.
+	maxImportSize = 64 << 20
+	// importTimeout replaces the server read and write timeouts for an import,
+	// which are too short to upload and copy a file of maxImportSize.
+	importTimeout = 10 * time.Minute
+	// exportFlushRows is the number of rows after which an export is flushed
+	// to the client.
+	exportFlushRows = 1000
 )
 
 type handler struct {
@@ -34,46 +60,225 @@ type Result struct {
 }
 
 type SumResult struct {
-	Sum int64 `json:"sum"`
+	Sum float64 `json:"sum"`
+}
+
+type GroupSumResult struct {
+	Groups []GroupSum `json:"groups"`
+}
+
+type MonthlySumResult struct {
+	Months []MonthlySum `json:"months"`
 }
 
+// CursorResult is a page of the cursor paginated list. NextCursor requests
+// the following page and is empty on the last one.
+type CursorResult struct {
+	Items      []Subscription `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// ListResult is a page of the offset paginated list. Total counts every
+// subscription matching the filters.
 type ListResult struct {
-	Result string         `json:"result"`
-	List   []Subscription `json:"list"`
+	Items  []Subscription `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
 }
 
 func (h *handler) Register(router *httprouter.Router) {
-	router.HandlerFunc(http.MethodGet, subscriptionsURL, apperror.Middleware(h.GetList))
-	router.HandlerFunc(http.MethodPost, subscriptionsURL, apperror.Middleware(h.Create))
-	router.HandlerFunc(http.MethodGet, subscriptionURL, apperror.Middleware(h.GetOne))
-	router.HandlerFunc(http.MethodPut, subscriptionURL, apperror.Middleware(h.Update))
-	router.HandlerFunc(http.MethodDelete, subscriptionURL, apperror.Middleware(h.Delete))
-	router.HandlerFunc(http.MethodGet, subscriptionsSumURL, apperror.Middleware(h.GetSum))
+	handlers.Route(router, http.MethodGet, subscriptionsURL, apperror.Middleware(h.GetList))
+	handlers.Route(router, http.MethodPost, subscriptionsURL, apperror.Middleware(h.Create))
+	handlers.Route(router, http.MethodPost, batchURL, apperror.Middleware(h.CreateBatch))
+	handlers.Route(router, http.MethodPost, importURL, apperror.Middleware(h.Import))
+	handlers.Route(router, http.MethodGet, exportURL, apperror.Middleware(h.Export))
+	handlers.Route(router, http.MethodGet, subscriptionURL, apperror.Middleware(h.GetOne))
+	handlers.Route(router, http.MethodPut, subscriptionURL, apperror.Middleware(h.Update))
+	handlers.Route(router, http.MethodPatch, subscriptionURL, apperror.Middleware(h.Patch))
+	handlers.Route(router, http.MethodDelete, subscriptionURL, apperror.Middleware(h.Delete))
+	handlers.Route(router, http.MethodPost, pricesURL, apperror.Middleware(h.AddPrice))
+	handlers.Route(router, http.MethodGet, subscriptionsSumURL, apperror.Middleware(h.GetSum))
+	handlers.Route(router, http.MethodGet, monthlySumURL, apperror.Middleware(h.GetMonthlySum))
 }
 
 func (h *handler) GetList(w http.ResponseWriter, r *http.Request) error {
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
-	user := r.URL.Query().Get("user_id")
-	service := r.URL.Query().Get("service_name")
+	filter, err := listFilter(r)
+	if err != nil {
+		return err
+	}
 	limit := helper.GetQueryInt(r, "limit", 20)
 	if limit > 1000 {
 		limit = 1000
 	}
+	if r.URL.Query().Has("cursor") {
+		return h.getListAfter(w, r, limit, r.URL.Query().Get("cursor"), filter)
+	}
 	offset := helper.GetQueryInt(r, "offset", 0)
-	all, err := h.repository.GetList(context.TODO(), limit, offset, from, to, user, service)
+	if limit < 0 || offset < 0 {
+		return apperror.NewBadRequestError("limit and offset cannot be negative")
+	}
+	items, total, err := h.repository.GetList(r.Context(), limit, offset, filter)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
-	allBytes, err := json.Marshal(all)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if links := listLinks(r, limit, offset, total); links != "" {
+		w.Header().Set("Link", links)
+	}
+
+	resultBytes, err := json.Marshal(ListResult{Items: items, Total: total, Limit: limit, Offset: offset})
 	if err != nil {
 		return err
 	}
 
 	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(allBytes)
+	_, err = w.Write(resultBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// listLinks builds the RFC 8288 Link header value with the first, prev, next
+// and last pages of an offset paginated list.
+func listLinks(r *http.Request, limit, offset, total int) string {
+	if limit == 0 {
+		return ""
+	}
+
+	link := func(rel string, offset int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+	}
+
+	last := 0
+	if total > 0 {
+		last = (total - 1) / limit * limit
+	}
+	links := []string{link("first", 0)}
+	if offset > 0 {
+		links = append(links, link("prev", max(offset-limit, 0)))
+	}
+	if offset+limit < total {
+		links = append(links, link("next", offset+limit))
+	}
+	links = append(links, link("last", last))
+
+	return strings.Join(links, ", ")
+}
+
+// Export streams every subscription matching the GetList filters as CSV,
+// NDJSON or XLSX. Rows are written as they are read from the database; once
+// the response has started a failure can only abort the connection.
+func (h *handler) Export(w http.ResponseWriter, r *http.Request) error {
+	format, contentType, ok := exportFormat(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if !ok {
+		return apperror.NewBadRequestError(fmt.Sprintf("invalid export format: %s", r.URL.Query().Get("format")))
+	}
+	tracing.SetAttributes(r.Context(), "format", format)
+	filter, err := listFilter(r)
+	if err != nil {
+		return err
+	}
+
+	rc := http.NewResponseController(w)
+	var e exporter
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"subscriptions.%s\"", format))
+		// the server write timeout is meant for regular requests
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.WriteHeader(http.StatusOK)
+
+		var err error
+		e, err = newExporter(format, w)
+		return err
+	}
+
+	rows := 0
+	err = h.repository.Export(r.Context(), filter, func(s Subscription) error {
+		if e == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := e.Write(s); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if c, ok := e.(*csvExporter); ok {
+				if err := c.Flush(); err != nil {
+					return err
+				}
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && e == nil {
+		err = start()
+	}
+	if err == nil {
+		err = e.Close()
+	} else if e != nil {
+		e.Abort()
+	}
+	if err != nil {
+		if !started {
+			return err
+		}
+		logging.FromContext(r.Context(), h.logger).Errorf("export aborted after %d rows: %v", rows, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// getListAfter serves the list in cursor mode. An empty cursor requests the
+// first page.
+func (h *handler) getListAfter(w http.ResponseWriter, r *http.Request, limit int, cursor string, filter ListFilter) error {
+	if limit < 1 {
+		return apperror.NewBadRequestError("limit must be positive")
+	}
+	var after *Cursor
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		after = &c
+	}
+
+	items, next, err := h.repository.GetListAfter(r.Context(), limit, after, filter)
+	if err != nil {
+		return err
+	}
+
+	result := CursorResult{Items: items, HasMore: next != nil}
+	if next != nil {
+		result.NextCursor = next.Encode()
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resultBytes)
 	if err != nil {
 		return err
 	}
@@ -82,13 +287,13 @@ func (h *handler) GetList(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (h *handler) GetSum(w http.ResponseWriter, r *http.Request) error {
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
-	user := r.URL.Query().Get("user_id")
-	service := r.URL.Query().Get("service_name")
-	sum, err := h.repository.GetSum(context.TODO(), from, to, user, service)
+	filter := sumFilter(r)
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		tracing.SetAttributes(r.Context(), "group_by", groupBy)
+		return h.getGroupedSum(w, r, filter, strings.Split(groupBy, ","))
+	}
+	sum, err := h.repository.GetSum(r.Context(), filter)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
@@ -106,18 +311,138 @@ func (h *handler) GetSum(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (h *handler) getGroupedSum(w http.ResponseWriter, r *http.Request, filter SumFilter, groupBy []string) error {
+	groups, err := h.repository.GetGroupedSum(r.Context(), filter, groupBy)
+	if err != nil {
+		return err
+	}
+
+	groupsBytes, err := json.Marshal(GroupSumResult{Groups: groups})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(groupsBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h *handler) GetMonthlySum(w http.ResponseWriter, r *http.Request) error {
+	months, err := h.repository.GetMonthlySum(r.Context(), sumFilter(r))
+	if err != nil {
+		return err
+	}
+
+	monthsBytes, err := json.Marshal(MonthlySumResult{Months: months})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(monthsBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// listFilter reads the list filters from the query. user_id and
+// service_name may be repeated to match any of the values, sort takes a comma
+// separated list of fields.
+func listFilter(r *http.Request) (ListFilter, error) {
+	query := r.URL.Query()
+	f := ListFilter{
+		From:          query.Get("from"),
+		To:            query.Get("to"),
+		DateMode:      query.Get("date_mode"),
+		Users:         nonEmpty(query["user_id"]),
+		Services:      nonEmpty(query["service_name"]),
+		ServicePrefix: query.Get("service_prefix"),
+		ActiveAt:      query.Get("active_at"),
+		Status:        query.Get("status"),
+	}
+	if sort := query.Get("sort"); sort != "" {
+		f.Sort = nonEmpty(strings.Split(sort, ","))
+	}
+	var err error
+	if f.PriceMin, err = queryPrice(query, "price_min"); err != nil {
+		return f, err
+	}
+	if f.PriceMax, err = queryPrice(query, "price_max"); err != nil {
+		return f, err
+	}
+
+	tracing.SetAttributes(r.Context(),
+		"user_id", strings.Join(f.Users, ","), "service_name", strings.Join(f.Services, ","), "service_prefix", f.ServicePrefix,
+		"from", f.From, "to", f.To, "date_mode", f.DateMode, "active_at", f.ActiveAt, "status", f.Status, "sort", strings.Join(f.Sort, ","))
+	return f, nil
+}
+
+// queryPrice returns the price in the query parameter, or nil when it is
+// not set.
+func queryPrice(query url.Values, name string) (*uint, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	p, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, apperror.NewBadRequestError(fmt.Sprintf("invalid %s value: %s", name, v))
+	}
+	price := uint(p)
+	return &price, nil
+}
+
+func nonEmpty(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sumFilter(r *http.Request) SumFilter {
+	f := SumFilter{
+		From:     r.URL.Query().Get("from"),
+		To:       r.URL.Query().Get("to"),
+		DateMode: r.URL.Query().Get("date_mode"),
+		User:     r.URL.Query().Get("user_id"),
+		Service:  r.URL.Query().Get("service_name"),
+		Mode:     r.URL.Query().Get("mode"),
+	}
+	tracing.SetAttributes(r.Context(), "user_id", f.User, "service_name", f.Service, "from", f.From, "to", f.To, "date_mode", f.DateMode, "mode", f.Mode)
+	return f
+}
+
+// decodeError turns a JSON decoding failure into a client error pointing at
+// the field when its value has a wrong type.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		var fields apperror.FieldErrors
+		fields.Add(typeErr.Field, apperror.FieldInvalidFormat, fmt.Sprintf("invalid %s value, expected %s", typeErr.Field, typeErr.Type))
+		return fields.Err()
+	}
+	return apperror.NewBadRequestError(fmt.Sprintf("invalid request body: %s", err))
+}
+
 func (h *handler) Create(w http.ResponseWriter, r *http.Request) error {
 	s := Subscription{}
 
 	err := json.NewDecoder(r.Body).Decode(&s)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return err
+		return decodeError(err)
 	}
 
-	err = h.repository.Create(context.TODO(), &s)
+	err = h.repository.Create(r.Context(), &s)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
@@ -135,17 +460,126 @@ func (h *handler) Create(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// CreateBatch creates an array of subscriptions. The response lists the
+// outcome of every item; a failed atomic batch answers with the status of
+// the failure and creates nothing.
+func (h *handler) CreateBatch(w http.ResponseWriter, r *http.Request) error {
+	atomic := false
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(v); err != nil {
+			return apperror.NewBadRequestError(fmt.Sprintf("invalid atomic value: %s", v))
+		}
+	}
+
+	var items []Subscription
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		return decodeError(err)
+	}
+	if len(items) == 0 {
+		return apperror.NewBadRequestError("batch is empty")
+	}
+	if len(items) > maxBatchSize {
+		return apperror.NewBadRequestError(fmt.Sprintf("batch cannot contain more than %d subscriptions", maxBatchSize))
+	}
+	tracing.SetAttributes(r.Context(), "atomic", strconv.FormatBool(atomic), "batch_size", strconv.Itoa(len(items)))
+
+	result, err := h.repository.CreateBatch(r.Context(), items, atomic)
+	if err != nil {
+		return err
+	}
+
+	status := http.StatusOK
+	if atomic && result.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+		for _, item := range result.Items {
+			if item.Status == BatchStatusConflict {
+				status = http.StatusConflict
+				break
+			}
+		}
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(resultBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Import loads subscriptions from a CSV body with a header row. Invalid rows
+// are listed by line number in the response and the valid rows are imported.
+func (h *handler) Import(w http.ResponseWriter, r *http.Request) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+		return apperror.NewBadRequestError("expected a text/csv request body")
+	}
+
+	var options ImportOptions
+	var err error
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		if options.DryRun, err = strconv.ParseBool(v); err != nil {
+			return apperror.NewBadRequestError(fmt.Sprintf("invalid dry_run value: %s", v))
+		}
+	}
+	switch onConflict := r.URL.Query().Get("on_conflict"); onConflict {
+	case "", "skip":
+	case "update":
+		options.Update = true
+	default:
+		return apperror.NewBadRequestError(fmt.Sprintf("invalid on_conflict value: %s", onConflict))
+	}
+	tracing.SetAttributes(r.Context(), "dry_run", strconv.FormatBool(options.DryRun), "on_conflict_update", strconv.FormatBool(options.Update))
+
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(importTimeout)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+
+	rows, err := NewCSVReader(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		return err
+	}
+
+	result, err := h.repository.Import(r.Context(), rows, options)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperror.NewBadRequestError(fmt.Sprintf("csv file cannot be larger than %d bytes", maxBytesErr.Limit))
+	}
+	if err != nil {
+		return err
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resultBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (h *handler) GetOne(w http.ResponseWriter, r *http.Request) error {
 	id, ok := helper.UuidFromContext(r.Context())
+	tracing.SetAttributes(r.Context(), "subscription_id", id)
 
 	if !ok {
-		http.NotFound(w, r)
-		return nil
+		return apperror.NewNotFoundError("subscription not found")
 	}
 
-	s, err := h.repository.FindOne(context.TODO(), id)
+	s, err := h.repository.FindOne(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
@@ -165,23 +599,111 @@ func (h *handler) GetOne(w http.ResponseWriter, r *http.Request) error {
 
 func (h *handler) Update(w http.ResponseWriter, r *http.Request) error {
 	id, ok := helper.UuidFromContext(r.Context())
+	tracing.SetAttributes(r.Context(), "subscription_id", id)
 
 	if !ok {
-		http.NotFound(w, r)
-		return nil
+		return apperror.NewNotFoundError("subscription not found")
 	}
 
 	s := Subscription{}
 
 	err := json.NewDecoder(r.Body).Decode(&s)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		return decodeError(err)
+	}
+
+	err = h.repository.Update(r.Context(), id, &s)
+	if err != nil {
 		return err
 	}
 
-	err = h.repository.Update(context.TODO(), id, &s)
+	sBytes, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(sBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) to the stored subscription, so
+// omitted fields are kept and null clears an optional field such as end_date.
+func (h *handler) Patch(w http.ResponseWriter, r *http.Request) error {
+	id, ok := helper.UuidFromContext(r.Context())
+	tracing.SetAttributes(r.Context(), "subscription_id", id)
+
+	if !ok {
+		return apperror.NewNotFoundError("subscription not found")
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apperror.NewBadRequestError(fmt.Sprintf("invalid request body: %s", err))
+	}
+
+	s, err := h.repository.Patch(r.Context(), id, func(s *Subscription) error {
+		sBytes, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		sBytes, err = helper.MergePatch(sBytes, patch)
+		if err != nil {
+			return apperror.NewBadRequestError(fmt.Sprintf("invalid merge patch: %s", err))
+		}
+
+		*s = Subscription{}
+		if err = json.Unmarshal(sBytes, s); err != nil {
+			return decodeError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sBytes, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(sBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h *handler) AddPrice(w http.ResponseWriter, r *http.Request) error {
+	id, ok := helper.UuidFromContext(r.Context())
+	tracing.SetAttributes(r.Context(), "subscription_id", id)
+
+	if !ok {
+		return apperror.NewNotFoundError("subscription not found")
+	}
+
+	p := Price{}
+
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	err = h.repository.AddPrice(r.Context(), id, &p)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.repository.FindOne(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
@@ -201,15 +723,14 @@ func (h *handler) Update(w http.ResponseWriter, r *http.Request) error {
 
 func (h *handler) Delete(w http.ResponseWriter, r *http.Request) error {
 	id, ok := helper.UuidFromContext(r.Context())
+	tracing.SetAttributes(r.Context(), "subscription_id", id)
 
 	if !ok {
-		http.NotFound(w, r)
-		return nil
+		return apperror.NewNotFoundError("subscription not found")
 	}
 
-	err := h.repository.Delete(context.TODO(), id)
+	err := h.repository.Delete(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return err
 	}
 
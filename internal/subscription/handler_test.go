@@ -0,0 +1,150 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tz1/pkg/logging"
+)
+
+// listRepository serves GetList from items, the other methods are not used.
+type listRepository struct {
+	Repository
+	items []Subscription
+}
+
+func (l listRepository) GetList(_ context.Context, limit int, offset int, _ ListFilter) ([]Subscription, int, error) {
+	start := min(offset, len(l.items))
+	end := min(offset+limit, len(l.items))
+	return l.items[start:end], len(l.items), nil
+}
+
+func TestGetList(t *testing.T) {
+	items := make([]Subscription, 25)
+	for i := range items {
+		items[i] = Subscription{ID: fmt.Sprintf("%d", i), ServiceName: "Netflix", Price: 100}
+	}
+	h := &handler{repository: listRepository{items: items}, logger: logging.GetLogger()}
+
+	tests := []struct {
+		name               string
+		query              string
+		wantCode           string
+		wantItems          int
+		wantLimit          int
+		wantOffset         int
+		wantLinkContains   string
+		wantLinkNotContain string
+	}{
+		{name: "defaults", query: "", wantItems: 20, wantLimit: 20, wantLinkContains: `offset=20>; rel="next"`, wantLinkNotContain: `rel="prev"`},
+		{name: "last page", query: "limit=10&offset=20", wantItems: 5, wantLimit: 10, wantOffset: 20, wantLinkContains: `offset=10>; rel="prev"`, wantLinkNotContain: `rel="next"`},
+		{name: "past the end", query: "limit=10&offset=30", wantItems: 0, wantLimit: 10, wantOffset: 30, wantLinkContains: `offset=20>; rel="last"`},
+		{name: "hard limit", query: "limit=5000", wantItems: 25, wantLimit: 1000},
+		{name: "negative limit", query: "limit=-1", wantCode: "US-000001"},
+		{name: "negative offset", query: "offset=-10", wantCode: "US-000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			err := h.GetList(w, httptest.NewRequest(http.MethodGet, "/subscriptions?"+tt.query, nil))
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			var got ListResult
+			if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Items) != tt.wantItems || got.Total != len(items) || got.Limit != tt.wantLimit || got.Offset != tt.wantOffset {
+				t.Errorf("got %d items, total %d, limit %d, offset %d; want %d items, total %d, limit %d, offset %d",
+					len(got.Items), got.Total, got.Limit, got.Offset, tt.wantItems, len(items), tt.wantLimit, tt.wantOffset)
+			}
+			if total := w.Header().Get("X-Total-Count"); total != "25" {
+				t.Errorf("X-Total-Count = %q, want 25", total)
+			}
+			link := w.Header().Get("Link")
+			if !strings.Contains(link, tt.wantLinkContains) {
+				t.Errorf("Link %q does not contain %q", link, tt.wantLinkContains)
+			}
+			if tt.wantLinkNotContain != "" && strings.Contains(link, tt.wantLinkNotContain) {
+				t.Errorf("Link %q contains %q", link, tt.wantLinkNotContain)
+			}
+		})
+	}
+}
+
+func TestListLinks(t *testing.T) {
+	// link mirrors the request below, whose other query parameters are kept
+	link := func(rel string, limit, offset int) string {
+		return fmt.Sprintf("</subscriptions?limit=%d&offset=%d&service_name=Netflix>; rel=\"%s\"", limit, offset, rel)
+	}
+
+	tests := []struct {
+		name                 string
+		limit, offset, total int
+		want                 []string
+	}{
+		{
+			name:  "zero limit",
+			limit: 0, offset: 0, total: 25,
+		},
+		{
+			name:  "empty list",
+			limit: 10, offset: 0, total: 0,
+			want: []string{link("first", 10, 0), link("last", 10, 0)},
+		},
+		{
+			name:  "single page",
+			limit: 10, offset: 0, total: 10,
+			want: []string{link("first", 10, 0), link("last", 10, 0)},
+		},
+		{
+			name:  "first page",
+			limit: 10, offset: 0, total: 25,
+			want: []string{link("first", 10, 0), link("next", 10, 10), link("last", 10, 20)},
+		},
+		{
+			name:  "middle page",
+			limit: 10, offset: 10, total: 25,
+			want: []string{link("first", 10, 0), link("prev", 10, 0), link("next", 10, 20), link("last", 10, 20)},
+		},
+		{
+			name:  "last page",
+			limit: 10, offset: 20, total: 25,
+			want: []string{link("first", 10, 0), link("prev", 10, 10), link("last", 10, 20)},
+		},
+		{
+			name:  "last page of an exact multiple",
+			limit: 10, offset: 10, total: 20,
+			want: []string{link("first", 10, 0), link("prev", 10, 0), link("last", 10, 10)},
+		},
+		{
+			name:  "unaligned offset",
+			limit: 10, offset: 5, total: 25,
+			want: []string{link("first", 10, 0), link("prev", 10, 0), link("next", 10, 15), link("last", 10, 20)},
+		},
+		{
+			name:  "past the end",
+			limit: 10, offset: 40, total: 25,
+			want: []string{link("first", 10, 0), link("prev", 10, 30), link("last", 10, 20)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions?service_name=Netflix&limit=%d&offset=%d", tt.limit, tt.offset), nil)
+			got := listLinks(r, tt.limit, tt.offset, tt.total)
+			if want := strings.Join(tt.want, ", "); got != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
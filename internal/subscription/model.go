@@ -1,10 +1,188 @@
 package subscription
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"tz1/pkg/apperror"
+)
+
+const (
+	BillingPeriodWeekly    = "weekly"
+	BillingPeriodMonthly   = "monthly"
+	BillingPeriodQuarterly = "quarterly"
+	BillingPeriodAnnual    = "annual"
+)
+
+const (
+	SumModeCash    = "cash"
+	SumModeAccrual = "accrual"
+)
+
+// Subscription has the initial Price, in force from StartDate. CurrentPrice is
+// the price in force in the current month; only the list and the export fill
+// it in and it is ignored on input.
 type Subscription struct {
-	ID          string `json:"id"`
-	ServiceName string `json:"service_name"`
-	Price       uint   `json:"price"`
-	User        string `json:"user_id"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date,omitempty"`
+	ID            string  `json:"id"`
+	ServiceName   string  `json:"service_name"`
+	Price         uint    `json:"price"`
+	CurrentPrice  uint    `json:"current_price,omitempty"`
+	BillingPeriod string  `json:"billing_period"`
+	User          string  `json:"user_id"`
+	StartDate     string  `json:"start_date"`
+	EndDate       string  `json:"end_date,omitempty"`
+	Prices        []Price `json:"prices,omitempty"`
+}
+
+// Batch item statuses.
+const (
+	BatchStatusCreated  = "created"
+	BatchStatusInvalid  = "invalid"
+	BatchStatusConflict = "conflict"
+	// BatchStatusRolledBack marks a valid item of an atomic batch that was not
+	// created because another item failed.
+	BatchStatusRolledBack = "rolled_back"
+)
+
+// BatchResult reports the outcome of every item of a batch create in the
+// order of the request.
+type BatchResult struct {
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Items   []BatchItem `json:"items"`
+}
+
+type BatchItem struct {
+	Index        int                `json:"index"`
+	Status       string             `json:"status"`
+	Subscription *Subscription      `json:"subscription,omitempty"`
+	Error        *apperror.AppError `json:"error,omitempty"`
+}
+
+// ImportReader yields the rows of an import. Read returns io.EOF after the
+// last row; Line is the source line of the row returned by the last Read.
+type ImportReader interface {
+	Read() (Subscription, error)
+	Line() int
+}
+
+type ImportOptions struct {
+	// DryRun reports what the import would do without writing anything.
+	DryRun bool
+	// Update overwrites the price, billing period and end date of existing
+	// subscriptions with the same user, service and start date instead of
+	// skipping them. Rows breaking the Repository.Update rules fail.
+	Update bool
+}
+
+// ImportResult counts the imported rows. Skipped rows matched an existing
+// subscription or a later row of the same import.
+type ImportResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type ImportRowError struct {
+	Line    int                   `json:"line"`
+	Message string                `json:"message"`
+	Fields  []apperror.FieldError `json:"fields,omitempty"`
+}
+
+// Price is a price per billing period in force from EffectiveFrom until the
+// next price change. The subscription Price is in force from its StartDate.
+type Price struct {
+	Price         uint   `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
+}
+
+// Cursor points after a subscription in the list order (start_date, id).
+// Clients get it as an opaque string.
+type Cursor struct {
+	StartDate string `json:"s"`
+	ID        string `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	cBytes, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(cBytes)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	cBytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(cBytes, &c)
+	}
+	if err != nil {
+		return c, apperror.NewBadRequestError("invalid cursor")
+	}
+	return c, nil
+}
+
+const (
+	StatusActive = "active"
+	StatusEnded  = "ended"
+)
+
+// Date modes tell how the From and To window of a filter selects
+// subscriptions: by start date, by end date, or by any overlap of the
+// subscription with the window.
+const (
+	DateModeStartsIn = "starts_in"
+	DateModeActiveIn = "active_in"
+	DateModeEndsIn   = "ends_in"
+)
+
+// ListFilter selects and orders the subscriptions of the list and the export.
+// Empty fields do not filter.
+type ListFilter struct {
+	// From and To bound the window applied according to DateMode, MM-YYYY.
+	From string
+	To   string
+	// DateMode defaults to DateModeStartsIn.
+	DateMode string
+	// Users and Services match any of the values.
+	Users    []string
+	Services []string
+	// ServicePrefix matches the start of the service name ignoring case.
+	ServicePrefix string
+	// PriceMin and PriceMax bound the price in force in the current month,
+	// which may differ from the initial Price after price changes.
+	PriceMin *uint
+	PriceMax *uint
+	// ActiveAt selects the subscriptions covering the month, MM-YYYY.
+	ActiveAt string
+	// Status is StatusActive or StatusEnded relative to the current month.
+	Status string
+	// Sort lists the fields to order by, descending when prefixed with "-".
+	Sort []string
+}
+
+// SumFilter selects the subscriptions and the period for the sum queries.
+type SumFilter struct {
+	From string
+	To   string
+	// DateMode defaults to DateModeActiveIn. Only the months of the
+	// subscriptions inside the window are summed in every mode.
+	DateMode string
+	User     string
+	Service  string
+	Mode     string
+}
+
+type MonthlySum struct {
+	Month string  `json:"month"`
+	Sum   float64 `json:"sum"`
+	Count int64   `json:"count"`
+}
+
+type GroupSum struct {
+	ServiceName string  `json:"service_name,omitempty"`
+	User        string  `json:"user_id,omitempty"`
+	Sum         float64 `json:"sum"`
+	Count       int64   `json:"count"`
 }
@@ -0,0 +1,62 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoded  string
+		want     Cursor
+		wantCode string
+	}{
+		{
+			name:    "round trip",
+			encoded: Cursor{StartDate: "2025-07-01", ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba"}.Encode(),
+			want:    Cursor{StartDate: "2025-07-01", ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba"},
+		},
+		{
+			name:    "empty cursor",
+			encoded: Cursor{}.Encode(),
+		},
+		{
+			name:     "padded base64",
+			encoded:  base64.URLEncoding.EncodeToString([]byte(`{"s":"2025-07-01","i":""}`)),
+			wantCode: "US-000001",
+		},
+		{
+			name:     "standard base64 alphabet",
+			encoded:  "eyJzIjoiMjAyNS0wNy0wMSIsImkiOiI/In0",
+			wantCode: "US-000001",
+		},
+		{
+			name:     "not json",
+			encoded:  base64.RawURLEncoding.EncodeToString([]byte("2025-07-01")),
+			wantCode: "US-000001",
+		},
+		{
+			name:     "wrong field type",
+			encoded:  base64.RawURLEncoding.EncodeToString([]byte(`{"s":20250701}`)),
+			wantCode: "US-000001",
+		},
+		{
+			name:     "empty string",
+			encoded:  "",
+			wantCode: "US-000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.encoded)
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("error = %v (code %q), want code %q", err, code, tt.wantCode)
+			}
+			if tt.wantCode == "" && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
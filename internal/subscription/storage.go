@@ -6,10 +6,29 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, subscription *Subscription) error
+	// CreateBatch creates the subscriptions and reports every item. An atomic
+	// batch creates either all of them or none.
+	CreateBatch(ctx context.Context, subscriptions []Subscription, atomic bool) (BatchResult, error)
+	// Import loads the rows in one transaction. Invalid rows are reported in
+	// the result and do not stop the import.
+	Import(ctx context.Context, rows ImportReader, options ImportOptions) (ImportResult, error)
 	FindAll(ctx context.Context) (s []Subscription, err error)
-	GetList(ctx context.Context, limit int, offset int, form string, to string, user string, service string) (s []Subscription, err error)
-	GetSum(ctx context.Context, form string, to string, user string, service string) (sum int64, err error)
+	// GetList returns a page of subscriptions and the number of all matching ones.
+	GetList(ctx context.Context, limit int, offset int, filter ListFilter) (s []Subscription, total int, err error)
+	// GetListAfter is the keyset paginated GetList. It returns the cursor of
+	// the next page, or nil on the last page.
+	GetListAfter(ctx context.Context, limit int, after *Cursor, filter ListFilter) (s []Subscription, next *Cursor, err error)
+	// Export calls fn for every subscription matching the GetList filters
+	// without loading them all into memory.
+	Export(ctx context.Context, filter ListFilter, fn func(Subscription) error) error
+	GetSum(ctx context.Context, filter SumFilter) (sum float64, err error)
+	GetGroupedSum(ctx context.Context, filter SumFilter, groupBy []string) (g []GroupSum, err error)
+	GetMonthlySum(ctx context.Context, filter SumFilter) (m []MonthlySum, err error)
 	FindOne(ctx context.Context, id string) (Subscription, error)
 	Update(ctx context.Context, id string, subscription *Subscription) error
+	// Patch changes the stored subscription with fn and saves it like Update
+	// in one transaction, so concurrent patches do not overwrite each other.
+	Patch(ctx context.Context, id string, fn func(subscription *Subscription) error) (Subscription, error)
 	Delete(ctx context.Context, id string) error
+	AddPrice(ctx context.Context, id string, price *Price) error
 }
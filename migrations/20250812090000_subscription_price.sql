@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.subscription_price
+(
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES public.subscription (id) ON DELETE CASCADE,
+    price           INT  NOT NULL,
+    effective_from  DATE NOT NULL,
+    UNIQUE (subscription_id, effective_from)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.subscription_price;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_subscription_active_range
+    ON public.subscription USING gist (daterange(start_date, end_date, '[]'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX public.idx_subscription_active_range;
+-- +goose StatementEnd
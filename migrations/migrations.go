@@ -0,0 +1,39 @@
+// Package migrations embeds the goose SQL migrations into the binary.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Version returns the version of the latest embedded migration, which is the
+// schema version the binary expects.
+func Version() (int64, error) {
+	entries, err := fs.ReadDir(FS, ".")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			return 0, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// NewProvider returns a goose provider that applies the embedded migrations
+// through the given pool, recording versions in table. Every run holds a
+// Postgres advisory lock, so replicas starting at the same time apply the
+// migrations one after another instead of racing. Closing the provider does
+// not close the pool.
+func NewProvider(pool *pgxpool.Pool, table string, logger goose.Logger) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration locker: %w", err)
+	}
+
+	provider, err := goose.NewProvider(
+		goose.DialectPostgres,
+		stdlib.OpenDBFromPool(pool),
+		FS,
+		goose.WithTableName(table),
+		goose.WithSessionLocker(locker),
+		goose.WithLogger(logger),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration provider: %w", err)
+	}
+
+	return provider, nil
+}
@@ -3,14 +3,17 @@ package apperror
 import "encoding/json"
 
 var (
-	ErrNotFound = NewAppError(nil, "not found", "", "US-000003")
+	ErrNotFound   = NewAppError(nil, "not found", "", "US-000003")
+	ErrConflict   = NewAppError(nil, "conflict", "", "US-000004")
+	ErrValidation = NewAppError(nil, "validation failed", "", "US-000005")
 )
 
 type AppError struct {
-	Err              error  `json:"-"`
-	Message          string `json:"message,omitempty"`
-	DeveloperMessage string `json:"developer_message,omitempty"`
-	Code             string `json:"code,omitempty"`
+	Err              error        `json:"-"`
+	Message          string       `json:"message,omitempty"`
+	DeveloperMessage string       `json:"developer_message,omitempty"`
+	Code             string       `json:"code,omitempty"`
+	Fields           []FieldError `json:"fields,omitempty"`
 }
 
 func (e *AppError) Error() string {
@@ -36,6 +39,24 @@ func NewAppError(err error, message, developerMessage, code string) *AppError {
 	}
 }
 
+// NewBadRequestError reports malformed request data such as an unreadable body
+// or invalid query parameters.
+func NewBadRequestError(message string) *AppError {
+	return NewAppError(nil, message, "", "US-000001")
+}
+
+func NewNotFoundError(message string) *AppError {
+	return NewAppError(ErrNotFound, message, "", ErrNotFound.Code)
+}
+
+func NewConflictError(message, developerMessage string) *AppError {
+	return NewAppError(ErrConflict, message, developerMessage, ErrConflict.Code)
+}
+
+func NewValidationError(message string) *AppError {
+	return NewAppError(ErrValidation, message, "", ErrValidation.Code)
+}
+
 func systemError(err error) *AppError {
 	return NewAppError(err, "internal system error", err.Error(), "US-000000")
 }
@@ -3,6 +3,7 @@ package apperror
 import (
 	"errors"
 	"net/http"
+	"tz1/pkg/logging"
 )
 
 type appHandler func(w http.ResponseWriter, r *http.Request) error
@@ -15,16 +16,26 @@ func Middleware(h appHandler) http.HandlerFunc {
 		if err != nil {
 			var appErr *AppError
 			if errors.As(err, &appErr) {
-				if errors.Is(err, ErrNotFound) {
-					http.Error(w, string(ErrNotFound.Marshal()), http.StatusNotFound)
-					return
+				switch {
+				case errors.Is(err, ErrNotFound):
+					writeError(w, appErr, http.StatusNotFound)
+				case errors.Is(err, ErrConflict):
+					writeError(w, appErr, http.StatusConflict)
+				case errors.Is(err, ErrValidation):
+					writeError(w, appErr, http.StatusUnprocessableEntity)
+				default:
+					writeError(w, appErr, http.StatusBadRequest)
 				}
-
-				http.Error(w, string(appErr.Marshal()), http.StatusBadRequest)
 				return
 			}
 
-			http.Error(w, string(systemError(err).Marshal()), http.StatusInternalServerError)
+			logging.FromContext(r.Context(), logging.GetLogger()).Error(err)
+			writeError(w, systemError(err), http.StatusInternalServerError)
 		}
 	}
 }
+
+func writeError(w http.ResponseWriter, appErr *AppError, status int) {
+	w.WriteHeader(status)
+	_, _ = w.Write(appErr.Marshal())
+}
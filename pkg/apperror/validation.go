@@ -0,0 +1,36 @@
+package apperror
+
+const (
+	FieldRequired      = "required"
+	FieldInvalidFormat = "invalid_format"
+	FieldInvalidValue  = "invalid_value"
+	FieldTooLong       = "too_long"
+	FieldOutOfRange    = "out_of_range"
+)
+
+// FieldError describes a problem with a single input field so that clients
+// can show it next to that field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// FieldErrors collects every problem found while validating an input instead
+// of stopping at the first one.
+type FieldErrors []FieldError
+
+func (f *FieldErrors) Add(field, code, message string) {
+	*f = append(*f, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns a validation error listing the collected fields, or nil when
+// there are none.
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	appErr := NewValidationError("validation failed")
+	appErr.Fields = f
+	return appErr
+}
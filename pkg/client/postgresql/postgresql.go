@@ -3,13 +3,17 @@ package postgresql
 import (
 	"context"
 	"fmt"
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 	"tz1/pkg/config"
 	"tz1/pkg/helper"
+	"tz1/pkg/logging"
 )
 
 type Client interface {
@@ -17,17 +21,39 @@ type Client interface {
 	Query(ctx context.Context, sql string, arguments ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, arguments ...any) pgx.Row
 	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
 }
 
 func NewClient(ctx context.Context, maxAttempts int, sc config.StorageConfig) (pool *pgxpool.Pool, err error) {
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", sc.Username, sc.Password, sc.Host, sc.Port, sc.Database)
+	logger := logging.GetLogger()
+
+	dsn, err := buildDSN(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgresql configuration %s: %w", dsn.Redacted(), err)
+	}
+	applyPoolConfig(poolConfig, sc)
+	// every statement gets a child span of the request span in ctx
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer()
+
 	err = helper.DoWithTries(func() error {
 		ctx, cancel := context.WithTimeout(ctx, 7*time.Second)
 		defer cancel()
 
-		pool, err = pgxpool.New(ctx, dsn)
+		pool, err = pgxpool.NewWithConfig(ctx, poolConfig)
 		if err != nil {
-			log.Printf("cannot connect psql. wait for retry...")
+			logger.Warnf("cannot create postgresql pool: %v. wait for retry...", err)
+			return err
+		}
+
+		// pgxpool connects lazily, so ping to make sure the database is reachable
+		if err = pool.Ping(ctx); err != nil {
+			logger.Warnf("cannot connect postgresql: %v. wait for retry...", err)
+			pool.Close()
 			return err
 		}
 
@@ -35,8 +61,66 @@ func NewClient(ctx context.Context, maxAttempts int, sc config.StorageConfig) (p
 	}, maxAttempts, 5*time.Second)
 
 	if err != nil {
-		log.Fatalf("error do with tries postgresql: %s", dsn)
+		return nil, fmt.Errorf("cannot connect postgresql %s after %d attempts: %w", dsn.Redacted(), maxAttempts, err)
 	}
 
 	return pool, nil
 }
+
+// buildDSN returns the connection URL from sc.URL or the separate connection
+// fields, with the TLS and application_name parameters of sc added. Parameters
+// already present in sc.URL win, so the config cannot weaken its sslmode.
+func buildDSN(sc config.StorageConfig) (*url.URL, error) {
+	dsn := &url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(sc.Username, sc.Password),
+		Host:   net.JoinHostPort(sc.Host, sc.Port),
+		Path:   "/" + sc.Database,
+	}
+	if sc.URL != "" {
+		var err error
+		dsn, err = url.Parse(sc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postgresql url: %w", err)
+		}
+	}
+
+	q := dsn.Query()
+	params := map[string]string{
+		"sslmode":          sc.SSLMode,
+		"sslrootcert":      sc.SSLRootCert,
+		"sslcert":          sc.SSLCert,
+		"sslkey":           sc.SSLKey,
+		"application_name": sc.ApplicationName,
+	}
+	for k, v := range params {
+		if v != "" && !q.Has(k) {
+			q.Set(k, v)
+		}
+	}
+	dsn.RawQuery = q.Encode()
+
+	return dsn, nil
+}
+
+// applyPoolConfig overrides the pgxpool defaults with the options set in sc.
+func applyPoolConfig(pc *pgxpool.Config, sc config.StorageConfig) {
+	if sc.MaxConns > 0 {
+		pc.MaxConns = sc.MaxConns
+	}
+	if sc.MinConns > 0 {
+		pc.MinConns = sc.MinConns
+	}
+	if sc.MaxConnLifetime > 0 {
+		pc.MaxConnLifetime = sc.MaxConnLifetime
+	}
+	if sc.MaxConnIdleTime > 0 {
+		pc.MaxConnIdleTime = sc.MaxConnIdleTime
+	}
+	if sc.HealthCheckPeriod > 0 {
+		pc.HealthCheckPeriod = sc.HealthCheckPeriod
+	}
+	if sc.StatementTimeout > 0 {
+		pc.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(sc.StatementTimeout.Milliseconds(), 10)
+	}
+}
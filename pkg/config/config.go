@@ -1,40 +1,109 @@
 package config
 
 import (
+	"errors"
 	"github.com/ilyakaznacheev/cleanenv"
+	"os"
 	"sync"
+	"time"
 	"tz1/pkg/logging"
 )
 
+// Every field can be overridden by an environment variable named after its
+// section and key with the TZ1_ prefix, e.g. TZ1_LISTEN_PORT or
+// TZ1_STORAGE_PASSWORD. Environment variables take precedence over the file.
 type Config struct {
 	Listen struct {
-		BindIp string `yaml:"bind_ip" env-default:""`
-		Port   string `yaml:"port" env-default:"8080"`
-	} `yaml:"listen"`
-	Storage StorageConfig `yaml:"storage"`
+		BindIp          string        `yaml:"bind_ip" env:"BIND_IP" env-default:""`
+		Port            string        `yaml:"port" env:"PORT" env-default:"8080"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s" env-description:"time to wait for in-flight requests on shutdown"`
+	} `yaml:"listen" env-prefix:"TZ1_LISTEN_"`
+	Storage StorageConfig  `yaml:"storage" env-prefix:"TZ1_STORAGE_"`
+	Tracing TracingConfig  `yaml:"tracing" env-prefix:"TZ1_TRACING_"`
+	Logging logging.Config `yaml:"logging" env-prefix:"TZ1_LOGGING_"`
 }
 
 type StorageConfig struct {
-	Host     string `yaml:"host" env-default:"postgres"`
-	Port     string `yaml:"port" env-default:"5432"`
-	Database string `yaml:"database" env-default:"tz1"`
-	Username string `yaml:"username" env-default:"local"`
-	Password string `yaml:"password" env-default:"admin"`
+	// URL is a complete connection string; when set it is used instead of
+	// the separate connection fields. DATABASE_URL is used when it is empty.
+	URL      string `yaml:"url" env:"URL" env-description:"postgres connection string, DATABASE_URL is used as a fallback"`
+	Host     string `yaml:"host" env:"HOST" env-default:"postgres"`
+	Port     string `yaml:"port" env:"PORT" env-default:"5432"`
+	Database string `yaml:"database" env:"DATABASE" env-default:"tz1"`
+	Username string `yaml:"username" env:"USERNAME" env-default:"local"`
+	Password string `yaml:"password" env:"PASSWORD" env-default:"admin"`
+	// MigrationsTable is the goose version table used by the migrate command
+	// and checked by the readiness probe.
+	MigrationsTable string `yaml:"migrations_table" env:"MIGRATIONS_TABLE" env-default:"public.goose_migrations"`
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool `yaml:"auto_migrate" env:"AUTO_MIGRATE" env-default:"false" env-description:"apply pending migrations on startup under an advisory lock"`
+
+	// Pool options, zero values keep the pgxpool defaults.
+	MaxConns          int32         `yaml:"max_conns" env:"MAX_CONNS"`
+	MinConns          int32         `yaml:"min_conns" env:"MIN_CONNS"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"MAX_CONN_LIFETIME"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"MAX_CONN_IDLE_TIME"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"HEALTH_CHECK_PERIOD"`
+	StatementTimeout  time.Duration `yaml:"statement_timeout" env:"STATEMENT_TIMEOUT" env-description:"server side statement timeout, 0 disables it"`
+
+	// Connection parameters added to the connection string when set and not
+	// already given in URL.
+	SSLMode         string `yaml:"sslmode" env:"SSLMODE" env-description:"disable, allow, prefer, require, verify-ca or verify-full"`
+	SSLRootCert     string `yaml:"sslrootcert" env:"SSLROOTCERT" env-description:"path to the root CA certificate"`
+	SSLCert         string `yaml:"sslcert" env:"SSLCERT" env-description:"path to the client certificate"`
+	SSLKey          string `yaml:"sslkey" env:"SSLKEY" env-description:"path to the client certificate key"`
+	ApplicationName string `yaml:"application_name" env:"APPLICATION_NAME" env-default:"tz1"`
+}
+
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Exporter    string  `yaml:"exporter" env:"EXPORTER" env-default:"stdout" env-description:"otlp or stdout"`
+	Endpoint    string  `yaml:"endpoint" env:"ENDPOINT" env-default:"localhost:4318" env-description:"OTLP/HTTP collector host:port"`
+	Insecure    bool    `yaml:"insecure" env:"INSECURE" env-default:"false"`
+	ServiceName string  `yaml:"service_name" env:"SERVICE_NAME" env-default:"tz1"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"SAMPLE_RATIO" env-default:"1"`
 }
 
+const defaultPath = "config.yml"
+
 var instance *Config
 var once sync.Once
 
+var path = defaultPath
+var pathIsSet bool
+
+// SetPath sets the configuration file read by GetConfig. It must be called
+// before the first GetConfig call.
+func SetPath(p string) {
+	path = p
+	pathIsSet = true
+}
+
+// GetConfig reads the configuration file and the environment once. Without
+// an explicitly set path a missing config.yml is not an error, so the service
+// can be configured by environment variables only.
 func GetConfig() *Config {
 	once.Do(func() {
 		logger := logging.GetLogger()
-		logger.Info("read application configuration")
 		instance = &Config{}
-		if err := cleanenv.ReadConfig("config.yml", instance); err != nil {
+
+		var err error
+		if _, statErr := os.Stat(path); errors.Is(statErr, os.ErrNotExist) && !pathIsSet {
+			logger.Infof("%s not found, read application configuration from environment", path)
+			err = cleanenv.ReadEnv(instance)
+		} else {
+			logger.Infof("read application configuration from %s", path)
+			err = cleanenv.ReadConfig(path, instance)
+		}
+		if err != nil {
 			help, _ := cleanenv.GetDescription(instance, nil)
 			logger.Info(help)
 			logger.Fatal(err)
 		}
+
+		if instance.Storage.URL == "" {
+			instance.Storage.URL = os.Getenv("DATABASE_URL")
+		}
 	})
 	return instance
 }
@@ -1,7 +1,18 @@
 package handlers
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"tz1/pkg/metrics"
+	"tz1/pkg/tracing"
+)
 
 type Handler interface {
 	Register(router *httprouter.Router)
 }
+
+// Route registers h for the method and route pattern wrapped into the tracing
+// and metrics middlewares, which label requests by the pattern.
+func Route(router *httprouter.Router, method, route string, h http.HandlerFunc) {
+	router.HandlerFunc(method, route, tracing.Middleware(route, metrics.Middleware(route, h)))
+}
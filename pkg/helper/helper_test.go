@@ -0,0 +1,69 @@
+package helper
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7396, Appendix A, and the subscription cases
+	tests := []struct {
+		name    string
+		target  string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{name: "replace member", target: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", target: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "remove member", target: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "remove one of two", target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "array replaces array", target: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "value replaces array", target: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{name: "nested", target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "arrays are not merged", target: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{name: "array patch", target: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{name: "array patch on object", target: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "null patch", target: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{name: "string patch", target: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{name: "null in target is kept", target: `{"e":null}`, patch: `{"a":1}`, want: `{"a":1,"e":null}`},
+		{name: "patch on array target", target: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{name: "nested null in new object", target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		{name: "clear end date", target: `{"price":400,"end_date":"12-2025"}`, patch: `{"end_date":null}`, want: `{"price":400}`},
+		{name: "empty patch", target: `{"price":400}`, patch: `{}`, want: `{"price":400}`},
+		{name: "invalid patch", target: `{}`, patch: `{"a":`, wantErr: true},
+		{name: "invalid target", target: `{`, patch: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.target), []byte(tt.patch))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := normalizeJSON(t, tt.want); string(got) != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// normalizeJSON re-encodes s the way MergePatch does, with sorted keys.
+func normalizeJSON(t *testing.T, s string) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
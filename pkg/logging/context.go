@@ -0,0 +1,19 @@
+package logging
+
+import "context"
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying the request-scoped logger.
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, or fallback
+// when ctx has none.
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return fallback
+}
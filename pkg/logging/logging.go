@@ -3,12 +3,38 @@ package logging
 import (
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config describes the log level, format and sinks. It is read as part of
+// the application configuration and applied by Init.
+type Config struct {
+	Level         string     `yaml:"level" env:"LEVEL" env-default:"info" env-description:"panic, fatal, error, warn, info, debug or trace"`
+	Format        string     `yaml:"format" env:"FORMAT" env-default:"text" env-description:"text or json"`
+	DisableStdout bool       `yaml:"disable_stdout" env:"DISABLE_STDOUT"`
+	File          FileConfig `yaml:"file" env-prefix:"FILE_"`
+}
+
+// FileConfig describes the rotated log file sink.
+type FileConfig struct {
+	Enabled    bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Path       string `yaml:"path" env:"PATH" env-default:"logs/all.log"`
+	MaxSizeMB  int    `yaml:"max_size_mb" env:"MAX_SIZE_MB" env-default:"100" env-description:"size that triggers rotation"`
+	MaxAgeDays int    `yaml:"max_age_days" env:"MAX_AGE_DAYS" env-default:"7" env-description:"days to keep rotated files, 0 keeps all"`
+	MaxBackups int    `yaml:"max_backups" env:"MAX_BACKUPS" env-default:"5" env-description:"rotated files to keep, 0 keeps all"`
+	Compress   bool   `yaml:"compress" env:"COMPRESS" env-default:"false"`
+}
+
 type writerHook struct {
 	Writer    []io.Writer
 	LogLevels []logrus.Level
@@ -34,6 +60,9 @@ func (hook *writerHook) Levels() []logrus.Level {
 
 var e *logrus.Entry
 
+// console receives the log lines meant for the terminal.
+var console io.Writer = os.Stdout
+
 type Logger struct {
 	*logrus.Entry
 }
@@ -46,36 +75,93 @@ func (l *Logger) GetLoggerWithField(k string, v interface{}) *Logger {
 	return &Logger{l.WithField(k, v)}
 }
 
-func init() {
-	l := logrus.New()
-	l.SetReportCaller(true)
-	l.Formatter = &logrus.TextFormatter{
-		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-			filename := path.Base(frame.File)
-			return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
-		},
-		DisableColors: false,
-		FullTimestamp: true,
-	}
+func callerPrettyfier(frame *runtime.Frame) (function string, file string) {
+	filename := path.Base(frame.File)
+	return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
+}
 
-	err := os.MkdirAll("logs", 0644)
+// Init applies the configuration to the logger shared by every Logger. Until
+// it is called logs go to stdout in text format at info level.
+func Init(cfg Config) error {
+	level, err := logrus.ParseLevel(cfg.Level)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	allFile, err := os.OpenFile("logs/all.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
-	if err != nil {
-		panic(err)
+	var formatter logrus.Formatter
+	switch cfg.Format {
+	case FormatText:
+		formatter = &logrus.TextFormatter{
+			CallerPrettyfier: callerPrettyfier,
+			DisableColors:    false,
+			FullTimestamp:    true,
+		}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{
+			CallerPrettyfier: callerPrettyfier,
+		}
+	default:
+		return fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	writers := make([]io.Writer, 0, 2)
+	if !cfg.DisableStdout {
+		writers = append(writers, console)
+	}
+	if cfg.File.Enabled {
+		if err = os.MkdirAll(filepath.Dir(cfg.File.Path), 0755); err != nil {
+			return err
+		}
+		writers = append(writers, &lumberjack.Logger{
+			Filename:   cfg.File.Path,
+			MaxSize:    cfg.File.MaxSizeMB,
+			MaxAge:     cfg.File.MaxAgeDays,
+			MaxBackups: cfg.File.MaxBackups,
+			Compress:   cfg.File.Compress,
+		})
+	}
+
+	l := e.Logger
+	l.Formatter = formatter
+	l.ReplaceHooks(make(logrus.LevelHooks))
+	l.AddHook(&writerHook{
+		Writer:    writers,
+		LogLevels: logrus.AllLevels,
+	})
+	l.SetLevel(level)
+
+	return nil
+}
+
+// SetConsole replaces stdout as the terminal sink, for example with stderr
+// when stdout carries command output. It must be called before Init.
+func SetConsole(w io.Writer) {
+	console = w
+	l := e.Logger
+	l.ReplaceHooks(make(logrus.LevelHooks))
+	l.AddHook(&writerHook{
+		Writer:    []io.Writer{console},
+		LogLevels: logrus.AllLevels,
+	})
+}
+
+func init() {
+	l := logrus.New()
+	l.SetReportCaller(true)
+	l.Formatter = &logrus.TextFormatter{
+		CallerPrettyfier: callerPrettyfier,
+		DisableColors:    false,
+		FullTimestamp:    true,
 	}
 
 	l.SetOutput(io.Discard)
 
 	l.AddHook(&writerHook{
-		Writer:    []io.Writer{allFile, os.Stdout},
+		Writer:    []io.Writer{console},
 		LogLevels: logrus.AllLevels,
 	})
 
-	l.SetLevel(logrus.TraceLevel)
+	l.SetLevel(logrus.InfoLevel)
 
 	e = logrus.NewEntry(l)
 }
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+	"tz1/pkg/helper"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// Middleware assigns every request an id, taken from the X-Request-ID header
+// when the client sends one, returns it in the response header and stores a
+// logger with the request_id field in the request context. After the request
+// it writes one access log line.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := GetLogger().GetLoggerWithField("request_id", id)
+		rec := helper.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r.WithContext(ContextWithLogger(r.Context(), logger)))
+
+		logger.WithFields(logrus.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      rec.Status,
+			"bytes":       rec.Bytes,
+			"duration":    time.Since(start).String(),
+			"remote_addr": r.RemoteAddr,
+		}).Info("access")
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	h := Middleware("/subscription/:uuid", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/subscription/1f0b8f5e-3c2a-4d6b-9a7e-2f1c3d4e5f60", nil))
+	ObserveQuery("FindOne", time.Now().Add(-5*time.Millisecond))
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`tz1_http_requests_total{method="GET",route="/subscription/:uuid",status="404"} 1`,
+		`tz1_http_request_duration_seconds_count{method="GET",route="/subscription/:uuid",status="404"} 1`,
+		`tz1_repository_query_duration_seconds_count{method="FindOne"} 1`,
+		`tz1_repository_query_duration_seconds_bucket{method="FindOne",le="0.01"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+	if strings.Contains(string(body), "1f0b8f5e") {
+		t.Error("metrics contain the request path instead of the route")
+	}
+}